@@ -0,0 +1,249 @@
+package duckgo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/ma6174/duckgo/internal/convert"
+)
+
+type appendOption struct {
+	schema       string
+	noCreate     bool
+	allowExtra   bool
+	allowMissing bool
+}
+
+// WithSchema makes AppendStructs append to a table of the given schema, rather than the current schema.
+func WithSchema(schema string) func(*appendOption) {
+	return func(o *appendOption) {
+		o.schema = schema
+	}
+}
+
+// WithoutCreate makes AppendStructs fail for a missing table, rather than create it.
+func WithoutCreate() func(*appendOption) {
+	return func(o *appendOption) {
+		o.noCreate = true
+	}
+}
+
+// AllowExtraColumns lets AppendStructs append to a table with columns that have no struct field.
+// They are set to their default value, or NULL.
+func AllowExtraColumns() func(*appendOption) {
+	return func(o *appendOption) {
+		o.allowExtra = true
+	}
+}
+
+// AllowMissingColumns lets AppendStructs append structs with fields that have no column in the table.
+// These fields are not stored.
+func AllowMissingColumns() func(*appendOption) {
+	return func(o *appendOption) {
+		o.allowMissing = true
+	}
+}
+
+// SchemaDriftError is returned by AppendStructs when the columns of a table differ from the fields of the structs.
+type SchemaDriftError struct {
+	Table   string
+	Extra   []string // Columns of the table without a struct field
+	Missing []string // Columns of the structs that the table lacks
+}
+
+func (e *SchemaDriftError) Error() string {
+	var diffs []string
+	if len(e.Extra) > 0 {
+		diffs = append(diffs, "extra table columns "+strings.Join(e.Extra, ", "))
+	}
+	if len(e.Missing) > 0 {
+		diffs = append(diffs, "missing table columns "+strings.Join(e.Missing, ", "))
+	}
+	return fmt.Sprintf("duckgo: table %s does not match the struct: %s", e.Table, strings.Join(diffs, "; "))
+}
+
+// AppendStructs bulk-inserts rows into a table of the database of conn through the DuckDB Appender.
+// T is a struct, or a pointer to a struct, mapped to columns like the rows of Scans: a column per exported
+// field, named after the field or its duckdb tag, with the SQL type and values UDFs use. Nil pointers are NULL.
+//
+//	err := duckgo.AppendStructs(conn, "events", events)
+//
+// A missing table is created with these columns, unless WithoutCreate is given. Fields are matched to
+// the columns of an existing table by name, case-insensitively, in any order. The columns of the table must
+// be those of the struct; otherwise a *SchemaDriftError lists the differences and no row is appended.
+// AllowExtraColumns and AllowMissingColumns accept tables with more or fewer columns.
+//
+// Rows are flushed to the table in chunks while appending, so rows before a failing one may be stored.
+// Run AppendStructs in a transaction of conn to append all rows or none. A table created by the call
+// is dropped again if appending fails, so that no partly filled table is left.
+func AppendStructs[T any](conn *sql.Conn, table string, rows []T, opts ...func(*appendOption)) (err error) {
+	var o appendOption
+	for _, opt := range opts {
+		opt(&o)
+	}
+	columns, err := rowColumns(reflect.TypeFor[[]T]())
+	if err != nil {
+		return fmt.Errorf("duckgo: table %s: %w", table, err)
+	}
+	tableName, tableColumns, err := lookupTable(conn, o.schema, table)
+	if err != nil {
+		return fmt.Errorf("duckgo: table %s: %w", table, err)
+	}
+	if tableColumns == nil {
+		if o.noCreate {
+			return fmt.Errorf("duckgo: table %s does not exist", table)
+		}
+		if err := createTable(conn, o.schema, table, reflect.TypeFor[[]T](), columns); err != nil {
+			return fmt.Errorf("duckgo: table %s: %w", table, err)
+		}
+		defer func() {
+			if err != nil {
+				err = errors.Join(err, dropTable(conn, o.schema, table))
+			}
+		}()
+		tableName = table
+		for _, c := range columns {
+			tableColumns = append(tableColumns, c.name)
+		}
+	}
+
+	// Match the columns of the structs with those of the table
+	byName := make(map[string]string, len(tableColumns))
+	for _, name := range tableColumns {
+		byName[strings.ToLower(name)] = name
+	}
+	drift := &SchemaDriftError{Table: table}
+	var appended []column
+	var names []string
+	for _, c := range columns {
+		name, ok := byName[strings.ToLower(c.name)]
+		if !ok {
+			drift.Missing = append(drift.Missing, c.name)
+			continue
+		}
+		delete(byName, strings.ToLower(c.name))
+		appended = append(appended, c)
+		names = append(names, name)
+	}
+	for _, name := range tableColumns {
+		if _, ok := byName[strings.ToLower(name)]; ok {
+			drift.Extra = append(drift.Extra, name)
+		}
+	}
+	if len(drift.Extra) > 0 && !o.allowExtra || len(drift.Missing) > 0 && !o.allowMissing {
+		return drift
+	}
+	if len(appended) == 0 {
+		return fmt.Errorf("duckgo: table %s has none of the columns of the struct", table)
+	}
+
+	err = conn.Raw(func(driverConn any) error {
+		appender, err := duckdb.NewAppenderWithColumns(driverConn.(driver.Conn), "", o.schema, tableName, names)
+		if err != nil {
+			return err
+		}
+		if err := appendRows(appender, appended, reflect.ValueOf(rows)); err != nil {
+			appender.Clear() // Discard the rows not flushed yet
+			appender.Close()
+			return err
+		}
+		return appender.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("duckgo: table %s: %w", table, err)
+	}
+	return nil
+}
+
+// lookupTable returns the name and columns of a table, or no columns if the table does not exist.
+func lookupTable(conn *sql.Conn, schema, table string) (string, []string, error) {
+	rows, err := conn.QueryContext(context.Background(), "SELECT table_name, column_name FROM duckdb_columns() "+
+		"WHERE database_name = current_database() AND schema_name = coalesce(nullif(?, ''), current_schema()) "+
+		"AND lower(table_name) = lower(?) ORDER BY column_index", schema, table)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+	var name string
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&name, &column); err != nil {
+			return "", nil, err
+		}
+		columns = append(columns, column)
+	}
+	return name, columns, rows.Err()
+}
+
+// createTable creates a table with the given columns for rows of type t.
+func createTable(conn *sql.Conn, schema, table string, t reflect.Type, columns []column) error {
+	elem := t.Elem()
+	if elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	defs := make([]string, len(columns))
+	for i, c := range columns {
+		sqlType, err := convert.SQLType(elem.Field(c.index).Type)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", c.name, err)
+		}
+		defs[i] = convert.QuoteIdent(c.name) + " " + sqlType
+	}
+	_, err := conn.ExecContext(context.Background(),
+		"CREATE TABLE "+qualifiedName(schema, table)+" ("+strings.Join(defs, ", ")+")")
+	return err
+}
+
+// dropTable drops a table created by createTable.
+func dropTable(conn *sql.Conn, schema, table string) error {
+	_, err := conn.ExecContext(context.Background(), "DROP TABLE IF EXISTS "+qualifiedName(schema, table))
+	if err != nil {
+		return fmt.Errorf("duckgo: dropping the created table %s: %w", table, err)
+	}
+	return nil
+}
+
+// qualifiedName returns the quoted name of a table of schema, or of the current schema.
+func qualifiedName(schema, table string) string {
+	name := convert.QuoteIdent(table)
+	if schema != "" {
+		name = convert.QuoteIdent(schema) + "." + name
+	}
+	return name
+}
+
+// appendRows appends the columns of the structs, or pointers to structs, of the slice rows.
+func appendRows(appender *duckdb.Appender, columns []column, rows reflect.Value) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic converting Go rows: %v", r)
+		}
+	}()
+	values := make([]driver.Value, len(columns))
+	for i := range rows.Len() {
+		row := rows.Index(i)
+		if row.Kind() == reflect.Pointer {
+			row = row.Elem() // Invalid for nil rows
+		}
+		for j, c := range columns {
+			values[j] = nil
+			if !row.IsValid() {
+				continue
+			}
+			if values[j], err = columnValue(row, c); err != nil {
+				return fmt.Errorf("row %d, column %s: %w", i, c.name, err)
+			}
+		}
+		if err := appender.AppendRow(values...); err != nil {
+			return fmt.Errorf("row %d: %w", i, err)
+		}
+	}
+	return nil
+}
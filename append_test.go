@@ -0,0 +1,127 @@
+package duckgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type event struct {
+	ID      int64 `duckdb:"id"`
+	Kind    string
+	At      time.Time
+	Score   *float64
+	Labels  map[string]int32
+	Payload []byte
+	Origin  struct{ Host string }
+}
+
+func TestAppendStructs(t *testing.T) {
+	_, conn := openConnector(t)
+	score := 0.5
+	at := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	events := []*event{
+		{ID: 1, Kind: "click", At: at, Score: &score, Labels: map[string]int32{"ab": 2}, Payload: []byte{1, 2}},
+		nil,
+	}
+	for i := range 3000 {
+		events = append(events, &event{ID: int64(i + 2), Kind: "view"})
+	}
+	require.NoError(t, AppendStructs(conn, "events", events))
+	require.NoError(t, AppendStructs(conn, "Events", events[:1]))
+
+	var n, nulls int
+	var kinds string
+	require.NoError(t, conn.QueryRowContext(context.Background(),
+		"SELECT count(*), count(*) FILTER (WHERE id IS NULL), string_agg(DISTINCT Kind, ',' ORDER BY Kind) FROM events").
+		Scan(&n, &nulls, &kinds))
+	require.Equal(t, 3003, n)
+	require.Equal(t, 1, nulls)
+	require.Equal(t, "click,view", kinds)
+
+	var gotAt time.Time
+	var gotScore float64
+	var labels any
+	var payload []byte
+	require.NoError(t, conn.QueryRowContext(context.Background(),
+		`SELECT "At", Score, Labels, Payload FROM events WHERE id = 1 LIMIT 1`).Scan(&gotAt, &gotScore, &labels, &payload))
+	require.True(t, gotAt.Equal(at))
+	require.Equal(t, score, gotScore)
+	labelMap, ok := labels.(duckdb.OrderedMap)
+	require.True(t, ok, "MAP column scanned as %T", labels)
+	ab, _ := labelMap.Get("ab")
+	require.Equal(t, int32(2), ab)
+	require.Equal(t, []byte{1, 2}, payload)
+
+	var typ string
+	require.NoError(t, conn.QueryRowContext(context.Background(),
+		"SELECT data_type FROM duckdb_columns() WHERE table_name = 'events' AND column_name = 'Origin'").Scan(&typ))
+	require.Equal(t, "STRUCT(Host VARCHAR)", typ)
+}
+
+func TestAppendStructsSchemaDrift(t *testing.T) {
+	_, conn := openConnector(t)
+	_, err := conn.ExecContext(context.Background(),
+		"CREATE SCHEMA audit; CREATE TABLE audit.logins (note VARCHAR DEFAULT 'none', NAME VARCHAR, id BIGINT)")
+	require.NoError(t, err)
+	type login struct {
+		ID   int64 `duckdb:"id"`
+		Name string
+	}
+	type loginV2 struct {
+		ID     int64 `duckdb:"id"`
+		Name   string
+		Device string
+	}
+
+	err = AppendStructs(conn, "logins", []login{{ID: 1}}, WithoutCreate())
+	require.ErrorContains(t, err, "table logins does not exist")
+
+	err = AppendStructs(conn, "logins", []loginV2{{ID: 1}}, WithSchema("audit"))
+	var drift *SchemaDriftError
+	require.True(t, errors.As(err, &drift), "got %v", err)
+	require.Equal(t, []string{"note"}, drift.Extra)
+	require.Equal(t, []string{"Device"}, drift.Missing)
+	require.EqualError(t, err, "duckgo: table logins does not match the struct: "+
+		"extra table columns note; missing table columns Device")
+
+	err = AppendStructs(conn, "logins", []loginV2{{ID: 1}}, WithSchema("audit"), AllowExtraColumns())
+	require.ErrorAs(t, err, &drift)
+	require.Equal(t, []string{"Device"}, drift.Missing)
+
+	require.NoError(t, AppendStructs(conn, "logins", []loginV2{{ID: 1, Name: "ada", Device: "phone"}},
+		WithSchema("audit"), AllowExtraColumns(), AllowMissingColumns()))
+	require.NoError(t, AppendStructs(conn, "logins", []login{{ID: 2, Name: "bob"}},
+		WithSchema("audit"), AllowExtraColumns()))
+	var rows string
+	require.NoError(t, conn.QueryRowContext(context.Background(),
+		"SELECT string_agg(id || ':' || NAME || ':' || note, ',' ORDER BY id) FROM audit.logins").Scan(&rows))
+	require.Equal(t, "1:ada:none,2:bob:none", rows)
+
+	require.ErrorContains(t, AppendStructs(conn, "bad", []struct{ C chan int }{}), "field C")
+}
+
+func TestAppendStructsDropsCreatedTable(t *testing.T) {
+	_, conn := openConnector(t)
+	type note struct {
+		ID int64 `duckdb:"id"`
+		At time.Time
+	}
+	// DuckDB timestamps end in the year 294247
+	notes := []note{{ID: 1, At: time.Now()}, {ID: 2, At: time.Date(300000, 1, 1, 0, 0, 0, 0, time.UTC)}}
+	require.Error(t, AppendStructs(conn, "notes", notes))
+	var n int
+	require.NoError(t, conn.QueryRowContext(context.Background(),
+		"SELECT count(*) FROM duckdb_tables() WHERE table_name = 'notes'").Scan(&n))
+	require.Zero(t, n, "the table created by the failed call is left")
+
+	// A table that existed before is kept
+	require.NoError(t, AppendStructs(conn, "notes", notes[:1]))
+	require.Error(t, AppendStructs(conn, "notes", notes[1:]))
+	require.NoError(t, conn.QueryRowContext(context.Background(), "SELECT count(*) FROM notes").Scan(&n))
+	require.Equal(t, 1, n)
+}
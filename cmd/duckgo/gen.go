@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const genUsage = `Usage: duckgo gen -func=Func1,importpath.Func2 [-dir package] [-o file]
+
+gen writes scalar UDF wrappers that call Go functions without reflection.
+For every function F it generates FUDF(opts ...udf.Option) (duckdb.ScalarFunc, error),
+which behaves like udf.BuildScalarUDF(F, opts...). Use it with go generate:
+
+	//go:generate go run github.com/ma6174/duckgo/cmd/duckgo gen -func=Add,Concat
+
+Flags:`
+
+// runGen executes the gen subcommand and returns its exit code.
+func runGen(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("duckgo gen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, genUsage)
+		fs.PrintDefaults()
+	}
+	funcs := fs.String("func", "", "comma-separated `functions`: names in the package, or importpath.Name for other packages")
+	dir := fs.String("dir", ".", "`directory` of the package the wrappers are generated for")
+	output := fs.String("o", "udf_gen.go", "output `file`, relative to -dir")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *funcs == "" || fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+
+	outPath := *output
+	if !filepath.IsAbs(outPath) {
+		outPath = filepath.Join(*dir, outPath)
+	}
+	src, err := generate(*dir, outPath, strings.Split(*funcs, ","))
+	if err != nil {
+		fmt.Fprintln(stderr, "duckgo gen:", err)
+		return 1
+	}
+	if err := os.WriteFile(outPath, src, 0o644); err != nil {
+		fmt.Fprintln(stderr, "duckgo gen:", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, "wrote", outPath)
+	return 0
+}
+
+// generator accumulates the generated code for one output file.
+type generator struct {
+	pkg      *types.Package    // Package the code is generated for
+	imports  map[string]string // Import path to package name used in the generated code
+	names    map[string]string // Package name to import path, to detect conflicts
+	pkgNames map[string]string // Import path to declared package name
+	body     bytes.Buffer
+}
+
+// generate returns the formatted source of the wrappers for funcNames, for the package in dir.
+func generate(dir, outPath string, funcNames []string) ([]byte, error) {
+	pkg, err := loadPackage(dir, ".", outPath)
+	if err != nil {
+		return nil, err
+	}
+	g := &generator{pkg: pkg.Types, imports: map[string]string{}, names: map[string]string{}, pkgNames: map[string]string{}}
+	// Imports must not collide with the declarations of the package
+	for _, name := range pkg.Types.Scope().Names() {
+		g.names[name] = ""
+	}
+	g.importName("database/sql/driver", "driver")
+	g.importName("github.com/duckdb/duckdb-go/v2", "duckdb")
+	g.importName("github.com/ma6174/duckgo/udf", "udf")
+
+	wrappers := map[string]string{}
+	for _, name := range funcNames {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		fn, err := lookupFunc(dir, pkg, name, outPath)
+		if err != nil {
+			return nil, err
+		}
+		wrapper := fn.Name() + "UDF"
+		if other, ok := wrappers[wrapper]; ok {
+			return nil, fmt.Errorf("functions %s and %s both need the wrapper name %s", other, name, wrapper)
+		}
+		wrappers[wrapper] = name
+		if err := g.writeWrapper(fn, wrapper); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if len(wrappers) == 0 {
+		return nil, errors.New("no functions given")
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by duckgo gen; DO NOT EDIT.\n\npackage %s\n\nimport (\n", g.pkg.Name())
+	var std, other []string
+	for path := range g.imports {
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			other = append(other, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	slices.Sort(std)
+	slices.Sort(other)
+	for i, group := range [][]string{std, other} {
+		if i > 0 && len(std) > 0 && len(other) > 0 {
+			out.WriteString("\n")
+		}
+		for _, path := range group {
+			if name := g.imports[path]; name != g.pkgNames[path] {
+				fmt.Fprintf(&out, "\t%s %s\n", name, strconv.Quote(path))
+			} else {
+				fmt.Fprintf(&out, "\t%s\n", strconv.Quote(path))
+			}
+		}
+	}
+	out.WriteString(")\n")
+	out.Write(g.body.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w\n%s", err, out.Bytes())
+	}
+	return src, nil
+}
+
+// loadPackage type-checks the package matching pattern in dir. The output file is replaced by an empty file
+// of the same package, so that an outdated version of it does not keep the package from compiling.
+func loadPackage(dir, pattern, outPath string) (*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedTypes | packages.NeedDeps | packages.NeedImports,
+		Dir:  dir,
+	}
+	if data, err := os.ReadFile(outPath); err == nil {
+		if f, err := parser.ParseFile(token.NewFileSet(), outPath, data, parser.PackageClauseOnly); err == nil {
+			abs, err := filepath.Abs(outPath)
+			if err != nil {
+				return nil, err
+			}
+			cfg.Overlay = map[string][]byte{abs: []byte("package " + f.Name.Name + "\n")}
+		}
+	}
+	pkgs, err := packages.Load(cfg, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("pattern %s matches %d packages", pattern, len(pkgs))
+	}
+	if len(pkgs[0].Errors) > 0 {
+		return nil, fmt.Errorf("loading %s: %v", pattern, pkgs[0].Errors[0])
+	}
+	return pkgs[0], nil
+}
+
+// lookupFunc finds the function name, either "Name" in pkg or "importpath.Name" in another package.
+func lookupFunc(dir string, pkg *packages.Package, name, outPath string) (*types.Func, error) {
+	scope := pkg.Types.Scope()
+	funcName := name
+	if i := strings.LastIndex(name, "."); i > 0 {
+		path := name[:i]
+		funcName = name[i+1:]
+		if path != pkg.PkgPath {
+			other, err := loadPackage(dir, path, outPath)
+			if err != nil {
+				return nil, err
+			}
+			if !token.IsExported(funcName) {
+				return nil, fmt.Errorf("%s is not exported", name)
+			}
+			scope = other.Types.Scope()
+		}
+	}
+	obj := scope.Lookup(funcName)
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return nil, fmt.Errorf("function %s not found", name)
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.TypeParams().Len() > 0 {
+		return nil, fmt.Errorf("%s is generic; instantiate it in a non-generic function first", name)
+	}
+	if sig.Results().Len() != 1 {
+		return nil, fmt.Errorf("%s must return exactly one value, but returns %d", name, sig.Results().Len())
+	}
+	return fn, nil
+}
+
+// importName records the import of path and returns the name it is referred to by in the generated code.
+func (g *generator) importName(path, name string) string {
+	if n, ok := g.imports[path]; ok {
+		return n
+	}
+	g.pkgNames[path] = name
+	base := name
+	for i := 2; ; i++ {
+		if _, taken := g.names[name]; !taken {
+			break
+		}
+		name = base + strconv.Itoa(i)
+	}
+	g.imports[path] = name
+	g.names[name] = path
+	return name
+}
+
+// qualifier names packages in type expressions of the generated code, importing them as needed.
+func (g *generator) qualifier(p *types.Package) string {
+	if p == g.pkg {
+		return ""
+	}
+	return g.importName(p.Path(), p.Name())
+}
+
+// typeString returns the Go expression for t in the generated code.
+func (g *generator) typeString(t types.Type) (string, error) {
+	if err := g.checkAccessible(t); err != nil {
+		return "", err
+	}
+	return types.TypeString(t, g.qualifier), nil
+}
+
+// checkAccessible returns an error if t refers to an unexported type of another package.
+func (g *generator) checkAccessible(t types.Type) error {
+	switch t := t.(type) {
+	case *types.Named:
+		obj := t.Obj()
+		if obj.Pkg() != nil && obj.Pkg() != g.pkg && !obj.Exported() {
+			return fmt.Errorf("type %s is not exported by %s", obj.Name(), obj.Pkg().Path())
+		}
+		if args := t.TypeArgs(); args != nil {
+			for i := range args.Len() {
+				if err := g.checkAccessible(args.At(i)); err != nil {
+					return err
+				}
+			}
+		}
+	case *types.Pointer:
+		return g.checkAccessible(t.Elem())
+	case *types.Slice:
+		return g.checkAccessible(t.Elem())
+	case *types.Array:
+		return g.checkAccessible(t.Elem())
+	case *types.Map:
+		if err := g.checkAccessible(t.Key()); err != nil {
+			return err
+		}
+		return g.checkAccessible(t.Elem())
+	}
+	return nil
+}
+
+// driverType returns the type of the value DuckDB passes for a parameter of Go type t, for the types
+// that are converted with a type assertion in the generated code. It must match the type mapping
+// of the internal convert package. Other types return "" and are converted with udf.ConvertArg.
+func driverType(t types.Type) string {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch u.Kind() {
+		case types.Int, types.Int8, types.Int16, types.Int32:
+			return "int32"
+		case types.Uint, types.Uint8, types.Uint16, types.Uint32:
+			return "uint32"
+		case types.Int64:
+			return "int64"
+		case types.Uint64:
+			return "uint64"
+		case types.Float32:
+			return "float32"
+		case types.Float64:
+			return "float64"
+		case types.String:
+			return "string"
+		case types.Bool:
+			return "bool"
+		}
+	case *types.Slice:
+		if b, ok := u.Elem().Underlying().(*types.Basic); ok && b.Kind() == types.Uint8 {
+			return "[]byte"
+		}
+	}
+	return ""
+}
+
+// writeWrapper generates the UDF constructor for fn.
+func (g *generator) writeWrapper(fn *types.Func, wrapper string) error {
+	sig := fn.Type().(*types.Signature)
+	callee := fn.Name()
+	if fn.Pkg() != g.pkg {
+		callee = g.importName(fn.Pkg().Path(), fn.Pkg().Name()) + "." + fn.Name()
+	}
+	if _, err := g.typeString(sig.Results().At(0).Type()); err != nil {
+		return err
+	}
+
+	w := &g.body
+	fmt.Fprintf(w, "\n// %s builds a scalar UDF for %s that calls it without reflection.\n", wrapper, callee)
+	fmt.Fprintf(w, "// It behaves like udf.BuildScalarUDF(%s, opts...).\n", callee)
+	fmt.Fprintf(w, "func %s(opts ...udf.Option) (duckdb.ScalarFunc, error) {\n", wrapper)
+	fmt.Fprintf(w, "\treturn udf.BuildGeneratedScalarUDF(%s, func(args []driver.Value) (func() any, error) {\n", callee)
+
+	params := sig.Params()
+	numFixed := params.Len()
+	if sig.Variadic() {
+		numFixed--
+	}
+	if params.Len() > 0 {
+		w.WriteString("\t\tvar err error\n")
+	}
+	callArgs := make([]string, params.Len())
+	for i := range numFixed {
+		typ, err := g.typeString(params.At(i).Type())
+		if err != nil {
+			return err
+		}
+		v := "a" + strconv.Itoa(i)
+		fmt.Fprintf(w, "\t\tvar %s %s\n", v, typ)
+		g.writeConversion(v, typ, params.At(i).Type(), strconv.Itoa(i), "false", "\t\t")
+		callArgs[i] = v
+	}
+	if sig.Variadic() {
+		elem := params.At(numFixed).Type().(*types.Slice).Elem()
+		typ, err := g.typeString(elem)
+		if err != nil {
+			return err
+		}
+		length, index := "len(args)", "i"
+		if numFixed > 0 {
+			length, index = fmt.Sprintf("len(args)-%d", numFixed), fmt.Sprintf("%d+i", numFixed)
+		}
+		fmt.Fprintf(w, "\t\tvariadic := make([]%s, %s)\n", typ, length)
+		w.WriteString("\t\tfor i := range variadic {\n")
+		g.writeConversion("variadic[i]", typ, elem, index, "true", "\t\t\t")
+		w.WriteString("\t\t}\n")
+		callArgs[numFixed] = "variadic..."
+	}
+	fmt.Fprintf(w, "\t\treturn func() any { return %s(%s) }, nil\n", callee, strings.Join(callArgs, ", "))
+	w.WriteString("\t}, opts...)\n}\n")
+	return nil
+}
+
+// writeConversion generates the conversion of args[index] into the variable v of Go type typ.
+func (g *generator) writeConversion(v, typ string, t types.Type, index, variadic, indent string) {
+	w := &g.body
+	if dt := driverType(t); dt != "" {
+		value := "v"
+		if dt != typ {
+			value = conversionExpr(typ, "v")
+		}
+		fmt.Fprintf(w, "%sif v, ok := args[%s].(%s); ok {\n", indent, index, dt)
+		fmt.Fprintf(w, "%s\t%s = %s\n", indent, v, value)
+		fmt.Fprintf(w, "%s} else if %s, err = udf.ConvertArg[%s](args, %s, %s); err != nil {\n", indent, v, typ, index, variadic)
+	} else {
+		fmt.Fprintf(w, "%sif %s, err = udf.ConvertArg[%s](args, %s, %s); err != nil {\n", indent, v, typ, index, variadic)
+	}
+	fmt.Fprintf(w, "%s\treturn nil, err\n%s}\n", indent, indent)
+}
+
+// conversionExpr returns the expression converting x to the type typ.
+func conversionExpr(typ, x string) string {
+	if strings.IndexFunc(typ, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.' }) >= 0 {
+		return "(" + typ + ")(" + x + ")" // e.g. ([]byte)(v)
+	}
+	return typ + "(" + x + ")"
+}
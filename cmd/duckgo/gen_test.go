@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const gentestDir = "../../udf/internal/gentest"
+
+// TestGenGolden regenerates the wrappers of the gentest package, whose tests compare them with the reflective UDFs,
+// and checks that the committed file is up to date.
+func TestGenGolden(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join(gentestDir, "funcs.go"))
+	require.NoError(t, err)
+	directive := regexp.MustCompile(`(?m)^//go:generate go run \S+ gen (.+)$`).FindSubmatch(data)
+	require.NotNil(t, directive)
+
+	out := filepath.Join(t.TempDir(), "udf_gen.go")
+	args := append([]string{"gen", "-dir", gentestDir, "-o", out}, strings.Fields(string(directive[1]))...)
+	code, _, stderr := runDuckgo(t, "", args...)
+	require.Equal(t, 0, code, stderr)
+
+	got, err := os.ReadFile(out)
+	require.NoError(t, err)
+	want, err := os.ReadFile(filepath.Join(gentestDir, "udf_gen.go"))
+	require.NoError(t, err)
+	require.Equal(t, string(want), string(got), "udf_gen.go is outdated, run go generate in %s", gentestDir)
+}
+
+func TestGenErrors(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/fns\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fns.go"), []byte(`package fns
+
+func Generic[T any](v T) T { return v }
+
+func Two() (int, error) { return 0, nil }
+
+var NotAFunc = 1
+
+func ToUpper(s string) string { return s }
+`), 0o644))
+
+	tests := []struct {
+		funcs  string
+		stderr string
+	}{
+		{"Missing", "function Missing not found"},
+		{"NotAFunc", "function NotAFunc not found"},
+		{"Generic", "Generic is generic"},
+		{"Two", "Two must return exactly one value, but returns 2"},
+		{"ToUpper,strings.ToUpper", "functions ToUpper and strings.ToUpper both need the wrapper name ToUpperUDF"},
+		{"strings.indexFunc", "strings.indexFunc is not exported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.funcs, func(t *testing.T) {
+			code, _, stderr := runDuckgo(t, "", "gen", "-dir", dir, "-func", tt.funcs)
+			require.Equal(t, 1, code)
+			require.Contains(t, stderr, tt.stderr)
+		})
+	}
+	_, err := os.Stat(filepath.Join(dir, "udf_gen.go"))
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,217 @@
+// Command duckgo is a DuckDB shell that can load Go and XGo scripts as UDFs.
+//
+// Usage:
+//
+//	duckgo [flags] [database]
+//
+// The database is a DuckDB file, or an in-memory database if omitted or ":memory:".
+// Scripts are loaded with the script package before any SQL runs:
+//
+//	duckgo -udf my_udfs.go:my_multiply,my_add -c "SELECT my_multiply(6, 7)" data.duckdb
+//	duckgo -udf-dir ./udfs -format json data.duckdb
+//
+// Without -c, SQL is read from standard input: interactively with line editing and history if it is a terminal,
+// or as a script otherwise. The SQL functions add_ixgo_udf and create_ixgo_udf are available as well,
+// and duckgo_udf_stats() returns the number of calls, errors and panics and the latency of every script function.
+//
+// The gen subcommand generates UDF wrappers that call compiled Go functions without reflection:
+//
+//	duckgo gen -func=Add,strings.ToUpper -o udf_gen.go
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	_ "github.com/duckdb/duckdb-go/v2"
+	"github.com/ma6174/duckgo/script"
+	"github.com/ma6174/duckgo/udf"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// listFlag collects the values of a flag that may be given several times.
+type listFlag []string
+
+func (f *listFlag) String() string {
+	return strings.Join(*f, " ")
+}
+
+func (f *listFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// shell holds the state of one duckgo session.
+type shell struct {
+	db     *sql.DB
+	loader *script.Loader
+	format string
+	out    io.Writer
+	errOut io.Writer
+}
+
+// run executes the duckgo command with the given arguments and returns its exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "gen" {
+		return runGen(args[1:], stdout, stderr)
+	}
+	fs := flag.NewFlagSet("duckgo", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: duckgo [flags] [database]")
+		fs.PrintDefaults()
+	}
+	var udfSpecs, udfDirs listFlag
+	fs.Var(&udfSpecs, "udf", "load functions from a script, as `file.go:funcA,funcB`; may be repeated")
+	fs.Var(&udfDirs, "udf-dir", "load all functions of the scripts in `dir`; may be repeated")
+	command := fs.String("c", "", "run `SQL` and exit")
+	format := fs.String("format", "table", "output format: table, csv or json")
+	maxSteps := fs.Int64("max-steps", 0, "limit the interpreter steps of a single script function call, 0 means no limit")
+	timeout := fs.Duration("timeout", 0, "limit the duration of a single UDF call, 0 means no limit")
+	catalog := fs.Bool("catalog", false, "record loaded scripts in the database and restore the recorded ones on open")
+	verbose := fs.Bool("v", false, "log every registered function")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+	if !validFormat(*format) {
+		fmt.Fprintf(stderr, "duckgo: unknown format %q, expected table, csv or json\n", *format)
+		return 2
+	}
+	if !*verbose {
+		log.SetOutput(io.Discard) // The script package logs every registered function
+	}
+
+	db, err := sql.Open("duckdb", databasePath(fs.Arg(0)))
+	if err != nil {
+		fmt.Fprintln(stderr, "duckgo:", err)
+		return 1
+	}
+	defer db.Close()
+
+	collector := udf.NewCollector()
+	opts := append(loaderOptions(*maxSteps, *timeout, *catalog), script.WithUDFOptions(udf.WithObserver(collector)))
+	sh := &shell{db: db, loader: script.NewLoader(opts...), format: *format, out: stdout, errOut: stderr}
+	if err := sh.setup(udfSpecs, udfDirs, opts, *catalog, collector); err != nil {
+		fmt.Fprintln(stderr, "duckgo:", err)
+		return 1
+	}
+
+	if *command != "" {
+		if err := sh.execute(*command); err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			return 1
+		}
+		return 0
+	}
+	if isTerminal(stdin) {
+		return sh.interactive()
+	}
+	return sh.runScript(stdin)
+}
+
+// databasePath maps the database argument to a DuckDB data source name.
+func databasePath(arg string) string {
+	if arg == ":memory:" {
+		return ""
+	}
+	return arg
+}
+
+// loaderOptions returns the script options for the command-line flags.
+func loaderOptions(maxSteps int64, timeout time.Duration, catalog bool) []script.Option {
+	opts := []script.Option{script.WithMaxSteps(maxSteps)}
+	if timeout > 0 {
+		opts = append(opts, script.WithUDFOptions(udf.WithTimeout(timeout)))
+	}
+	if catalog {
+		opts = append(opts, script.WithCatalog())
+	}
+	return opts
+}
+
+// setup restores the catalog if requested, loads the scripts given on the command line,
+// enables loading more scripts from SQL and registers duckgo_udf_stats() for the counters of collector.
+func (sh *shell) setup(udfSpecs, udfDirs []string, opts []script.Option, catalog bool, collector *udf.Collector) error {
+	conn, err := sh.db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := udf.RegisterStatsFunction(conn, collector); err != nil {
+		return err
+	}
+	if catalog {
+		if err := script.RestoreFromCatalog(sh.db, opts...); err != nil {
+			return err
+		}
+	}
+	for _, spec := range udfSpecs {
+		if err := sh.loadUDF(spec); err != nil {
+			return err
+		}
+	}
+	for _, dir := range udfDirs {
+		if _, err := sh.loader.AddIXGoUDFFromDir(sh.db, dir); err != nil {
+			return err
+		}
+	}
+	if err := script.EnableRegisterUDFFromSQL(sh.db, opts...); err != nil {
+		return err
+	}
+	return script.EnableCreateUDFFromSQL(sh.db, opts...)
+}
+
+// loadUDF loads the functions named by a -udf value of the form file.go:funcA,funcB.
+func (sh *shell) loadUDF(spec string) error {
+	filename, funcNames, err := parseUDFSpec(spec)
+	if err != nil {
+		return err
+	}
+	return sh.loader.AddIXGoUDFFromFile(sh.db, filename, funcNames...)
+}
+
+// parseUDFSpec splits a -udf value into the script file and the function names.
+// Function names may use the "alias=name" form of script.Loader.
+func parseUDFSpec(spec string) (filename string, funcNames []string, err error) {
+	i := strings.LastIndex(spec, ":")
+	if i <= 0 || i == len(spec)-1 {
+		return "", nil, fmt.Errorf("invalid -udf value %q, expected file.go:funcA,funcB", spec)
+	}
+	for _, name := range strings.Split(spec[i+1:], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			funcNames = append(funcNames, name)
+		}
+	}
+	if len(funcNames) == 0 {
+		return "", nil, fmt.Errorf("invalid -udf value %q, expected file.go:funcA,funcB", spec)
+	}
+	return spec[:i], funcNames, nil
+}
+
+// isTerminal reports whether r is an interactive terminal.
+func isTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testScript = `package main
+
+func my_add(a, b int) int { return a + b }
+
+func shout(s string) string { return s + "!" }
+
+func worker(c chan int) {}
+`
+
+func runDuckgo(t *testing.T, stdin string, args ...string) (code int, stdout, stderr string) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	code = run(args, strings.NewReader(stdin), &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	scriptPath := filepath.Join(dir, "udfs.go")
+	require.NoError(t, os.WriteFile(scriptPath, []byte(testScript), 0o644))
+
+	tests := []struct {
+		name     string
+		stdin    string
+		args     []string
+		code     int
+		expected string
+		stderr   string
+	}{
+		{
+			name: "table",
+			args: []string{"-udf", scriptPath + ":my_add,plus=my_add", "-c", "SELECT my_add(1, 2) AS a, plus(3, 4) AS b, NULL AS n"},
+			expected: `┌───┬───┬──────┐
+│ a │ b │ n    │
+├───┼───┼──────┤
+│ 3 │ 7 │ NULL │
+└───┴───┴──────┘
+1 row
+`,
+		},
+		{
+			name:     "csv",
+			args:     []string{"-udf", scriptPath + ":shout", "-format", "csv", "-c", "SELECT shout('a,b') AS s, NULL AS n"},
+			expected: "s,n\n\"a,b!\",\n",
+		},
+		{
+			name:     "json",
+			args:     []string{"-udf-dir", dir, "-format", "json", "-c", "SELECT my_add(1, 1) AS a, shout('x') AS s, [1, 2] AS l, {'k': 'v'} AS st"},
+			expected: "[\n  {\"a\": 2, \"s\": \"x!\", \"l\": [1,2], \"st\": {\"k\":\"v\"}}\n]\n",
+		},
+		{
+			name:  "script from stdin",
+			stdin: "CREATE TABLE t (a INT);\nINSERT INTO t VALUES (1), (2);\n.mode csv\nSELECT a,\n  a * 2 AS b\nFROM t ORDER BY a;\nSELECT create_ixgo_udf('func neg(x int) int { return -x }', 'neg');\n.mode json\nSELECT neg(5) AS n;\n",
+			expected: "┌───────┐\n│ Count │\n├───────┤\n│ 2     │\n└───────┘\n1 row\n" +
+				"a,b\n1,2\n2,4\n\"create_ixgo_udf('func neg(x int) int { return -x }', 'neg')\"\n1\n[\n  {\"n\": -5}\n]\n",
+		},
+		{
+			name:     "udf stats",
+			args:     []string{"-udf", scriptPath + ":my_add", "-format", "csv", "-c", "SELECT name, calls, errors, panics FROM duckgo_udf_stats()"},
+			expected: "name,calls,errors,panics\n",
+		},
+		{
+			name:     "udf stats after calls",
+			stdin:    "SELECT sum(my_add(i::INTEGER, 1)) AS s FROM range(3) t(i);\nSELECT name, calls, errors, panics FROM duckgo_udf_stats();\n",
+			args:     []string{"-udf", scriptPath + ":my_add", "-format", "csv"},
+			expected: "s\n6\nname,calls,errors,panics\nmy_add,3,0,0\n",
+		},
+		{
+			name:   "sql error",
+			args:   []string{"-c", "SELECT no_such_function(1)"},
+			code:   1,
+			stderr: "Error: Catalog Error: Scalar Function with name no_such_function does not exist",
+		},
+		{
+			name:   "invalid udf flag",
+			args:   []string{"-udf", scriptPath},
+			code:   1,
+			stderr: "expected file.go:funcA,funcB",
+		},
+		{
+			name:   "unknown format",
+			args:   []string{"-format", "xml"},
+			code:   2,
+			stderr: `unknown format "xml"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := runDuckgo(t, tt.stdin, tt.args...)
+			require.Equal(t, tt.code, code, stderr)
+			if tt.stderr != "" {
+				require.Contains(t, stderr, tt.stderr)
+				return
+			}
+			require.Equal(t, tt.expected, stdout)
+		})
+	}
+}
+
+func TestParseUDFSpec(t *testing.T) {
+	filename, funcNames, err := parseUDFSpec("C:/udfs/my.go:a, b=c ,")
+	require.NoError(t, err)
+	require.Equal(t, "C:/udfs/my.go", filename)
+	require.Equal(t, []string{"a", "b=c"}, funcNames)
+
+	for _, spec := range []string{"my.go", "my.go:", ":a", "my.go:,"} {
+		_, _, err := parseUDFSpec(spec)
+		require.Error(t, err, spec)
+	}
+}
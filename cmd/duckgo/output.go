@@ -0,0 +1,257 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+// validFormat reports whether format is a supported output format.
+func validFormat(format string) bool {
+	return format == "table" || format == "csv" || format == "json"
+}
+
+// writeRows prints all rows in the given format. Statements without a result, such as CREATE TABLE, print nothing.
+func writeRows(w io.Writer, format string, rows *sql.Rows) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	var records [][]any
+	for rows.Next() {
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		records = append(records, values)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	// DuckDB reports statements without a result as an empty "Count" column
+	if len(columns) == 0 || len(records) == 0 && slices.Equal(columns, []string{"Count"}) {
+		return nil
+	}
+	switch format {
+	case "csv":
+		return writeCSV(w, columns, records)
+	case "json":
+		return writeJSON(w, columns, records)
+	default:
+		return writeTable(w, columns, records)
+	}
+}
+
+// writeTable prints the records as a table with box-drawing borders, like the DuckDB shell.
+func writeTable(w io.Writer, columns []string, records [][]any) error {
+	widths := make([]int, len(columns))
+	for i, c := range columns {
+		widths[i] = runewidth.StringWidth(c)
+	}
+	cells := make([][]string, len(records))
+	for r, record := range records {
+		cells[r] = make([]string, len(record))
+		for i, v := range record {
+			// Keep every row on one line
+			s := strings.NewReplacer("\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(formatValue(v))
+			cells[r][i] = s
+			widths[i] = max(widths[i], runewidth.StringWidth(s))
+		}
+	}
+
+	border := func(left, mid, right string) string {
+		parts := make([]string, len(widths))
+		for i, width := range widths {
+			parts[i] = strings.Repeat("─", width+2)
+		}
+		return left + strings.Join(parts, mid) + right + "\n"
+	}
+	line := func(values []string) string {
+		var b strings.Builder
+		b.WriteString("│")
+		for i, v := range values {
+			b.WriteString(" ")
+			b.WriteString(v)
+			b.WriteString(strings.Repeat(" ", widths[i]-runewidth.StringWidth(v)))
+			b.WriteString(" │")
+		}
+		b.WriteString("\n")
+		return b.String()
+	}
+
+	var b strings.Builder
+	b.WriteString(border("┌", "┬", "┐"))
+	b.WriteString(line(columns))
+	b.WriteString(border("├", "┼", "┤"))
+	for _, row := range cells {
+		b.WriteString(line(row))
+	}
+	b.WriteString(border("└", "┴", "┘"))
+	if len(records) == 1 {
+		b.WriteString("1 row\n")
+	} else {
+		fmt.Fprintf(&b, "%d rows\n", len(records))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeCSV prints the records as CSV with a header line. NULL is printed as an empty field.
+func writeCSV(w io.Writer, columns []string, records [][]any) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, record := range records {
+		fields := make([]string, len(record))
+		for i, v := range record {
+			if v != nil {
+				fields[i] = formatValue(v)
+			}
+		}
+		if err := cw.Write(fields); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeJSON prints the records as a JSON array of objects, keeping the column order.
+func writeJSON(w io.Writer, columns []string, records [][]any) error {
+	var b strings.Builder
+	b.WriteString("[")
+	for r, record := range records {
+		if r > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n  {")
+		for i, v := range record {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			key, err := json.Marshal(columns[i])
+			if err != nil {
+				return err
+			}
+			value, err := json.Marshal(jsonValue(v))
+			if err != nil {
+				return fmt.Errorf("column %s: %w", columns[i], err)
+			}
+			b.Write(key)
+			b.WriteString(": ")
+			b.Write(value)
+		}
+		b.WriteString("}")
+	}
+	if len(records) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("]\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// formatValue renders a value scanned from DuckDB as text, in the notation of the DuckDB shell.
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return v
+	case []byte:
+		if utf8.Valid(v) {
+			return string(v)
+		}
+		return `\x` + hex.EncodeToString(v)
+	case time.Time:
+		if v.Location() == time.UTC {
+			return v.Format("2006-01-02 15:04:05.999999")
+		}
+		return v.Format("2006-01-02 15:04:05.999999-07:00")
+	case duckdb.UUID:
+		return v.String()
+	case []any:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = formatValue(e)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case map[string]any:
+		keys := sortedKeys(v)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = fmt.Sprintf("'%s': %s", k, formatValue(v[k]))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case duckdb.OrderedMap:
+		keys, values := v.Keys(), v.Values()
+		parts := make([]string, len(keys))
+		for i := range keys {
+			parts[i] = formatValue(keys[i]) + "=" + formatValue(values[i])
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// jsonValue converts a value scanned from DuckDB into a value encoding/json can marshal.
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case []byte:
+		if utf8.Valid(v) {
+			return string(v)
+		}
+		return v // Base64
+	case duckdb.UUID:
+		return v.String()
+	case duckdb.Decimal:
+		return json.Number(v.String())
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = jsonValue(e)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = jsonValue(e)
+		}
+		return out
+	case duckdb.OrderedMap:
+		keys, values := v.Keys(), v.Values()
+		out := make(map[string]any, len(keys))
+		for i := range keys {
+			out[formatValue(keys[i])] = jsonValue(values[i])
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// sortedKeys returns the keys of m in ascending order.
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/peterh/liner"
+)
+
+const (
+	prompt             = "D "
+	continuationPrompt = "‣ "
+)
+
+const helpText = `.help                       Show this help
+.mode table|csv|json        Set the output format
+.udf file.go:funcA,funcB    Load functions from a script
+.udfdir dir                 Load all functions of the scripts in dir
+.quit                       Exit (also .exit or Ctrl-D)
+SQL statements end with a semicolon and may span several lines.`
+
+// errQuit is returned by dotCommand when the session should end.
+var errQuit = errors.New("quit")
+
+// interactive reads statements from the terminal with line editing and history until the user quits.
+func (sh *shell) interactive() int {
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+
+	historyPath := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		historyPath = filepath.Join(home, ".duckgo_history")
+		if f, err := os.Open(historyPath); err == nil {
+			line.ReadHistory(f)
+			f.Close()
+		}
+	}
+	defer func() {
+		if historyPath == "" {
+			return
+		}
+		if f, err := os.Create(historyPath); err == nil {
+			line.WriteHistory(f)
+			f.Close()
+		}
+	}()
+
+	fmt.Fprintln(sh.out, `Enter ".help" for usage hints.`)
+	var stmt strings.Builder
+	for {
+		p := prompt
+		if stmt.Len() > 0 {
+			p = continuationPrompt
+		}
+		input, err := line.Prompt(p)
+		if errors.Is(err, liner.ErrPromptAborted) {
+			stmt.Reset() // Ctrl-C discards the statement being typed
+			continue
+		}
+		if err != nil { // io.EOF on Ctrl-D
+			fmt.Fprintln(sh.out)
+			return 0
+		}
+		err = sh.feed(&stmt, input)
+		if strings.TrimSpace(input) != "" {
+			line.AppendHistory(input)
+		}
+		if errors.Is(err, errQuit) {
+			return 0
+		}
+		if err != nil {
+			fmt.Fprintln(sh.errOut, "Error:", err)
+		}
+	}
+}
+
+// runScript executes the statements read from r and stops at the first error.
+func (sh *shell) runScript(r io.Reader) int {
+	var stmt strings.Builder
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		err := sh.feed(&stmt, scanner.Text())
+		if errors.Is(err, errQuit) {
+			return 0
+		}
+		if err != nil {
+			fmt.Fprintln(sh.errOut, "Error:", err)
+			return 1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(sh.errOut, "Error:", err)
+		return 1
+	}
+	if strings.TrimSpace(stmt.String()) != "" {
+		if err := sh.execute(stmt.String()); err != nil {
+			fmt.Fprintln(sh.errOut, "Error:", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// feed adds a line of input to the statement being collected in stmt. Dot commands are run at once,
+// SQL is run when the line ends with a semicolon.
+func (sh *shell) feed(stmt *strings.Builder, input string) error {
+	trimmed := strings.TrimSpace(input)
+	if stmt.Len() == 0 && strings.HasPrefix(trimmed, ".") {
+		return sh.dotCommand(trimmed)
+	}
+	if stmt.Len() == 0 && trimmed == "" {
+		return nil
+	}
+	stmt.WriteString(input)
+	stmt.WriteByte('\n')
+	if !strings.HasSuffix(trimmed, ";") {
+		return nil
+	}
+	query := stmt.String()
+	stmt.Reset()
+	return sh.execute(query)
+}
+
+// dotCommand runs a shell command such as ".mode csv".
+func (sh *shell) dotCommand(input string) error {
+	cmd, arg, _ := strings.Cut(input, " ")
+	arg = strings.TrimSpace(arg)
+	switch cmd {
+	case ".quit", ".exit":
+		return errQuit
+	case ".help":
+		fmt.Fprintln(sh.out, helpText)
+	case ".mode":
+		if !validFormat(arg) {
+			return fmt.Errorf("unknown format %q, expected table, csv or json", arg)
+		}
+		sh.format = arg
+	case ".udf":
+		return sh.loadUDF(arg)
+	case ".udfdir":
+		names, err := sh.loader.AddIXGoUDFFromDir(sh.db, arg)
+		if len(names) > 0 {
+			fmt.Fprintln(sh.out, "Registered:", strings.Join(names, ", "))
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown command %s, enter .help for usage hints", cmd)
+	}
+	return nil
+}
+
+// execute runs query and prints its result in the current format.
+func (sh *shell) execute(query string) error {
+	rows, err := sh.db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	return writeRows(sh.out, sh.format, rows)
+}
@@ -0,0 +1,290 @@
+package duckgo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"iter"
+	"reflect"
+	"runtime"
+	"sync"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/ma6174/duckgo/internal/convert"
+)
+
+// RegisterCollection serves the rows returned by fn as the table name of the database of conn.
+// fn is called for every query of the table, so queries always see the current contents of the collection:
+//
+//	duckgo.RegisterCollection(conn, "sessions", func() []Session { return store.Sessions() })
+//	// SELECT user_id, count(*) FROM sessions GROUP BY user_id
+//
+// T is a struct, or a pointer to a struct, mapped to columns like the rows of Scans. Only the columns read
+// by a query are converted from the Go values.
+//
+// The table is a temporary view over the table function name(): the view is only visible to conn and is not
+// stored in the database file, while name() can be queried by every connection to the database. A name can be
+// registered once per database, and must not be the name of an existing table or view.
+func RegisterCollection[T any](conn *sql.Conn, name string, fn func() []T) error {
+	columns, err := rowColumns(reflect.TypeFor[[]T]())
+	if err != nil {
+		return fmt.Errorf("duckgo: collection %s: %w", name, err)
+	}
+	return registerView(conn, name, columns, func(context.Context) (duckdb.ChunkTableSource, error) {
+		rows, err := callGo(fn)
+		if err != nil {
+			return nil, err
+		}
+		return &sliceSource{columns: columns, rows: reflect.ValueOf(rows)}, nil
+	})
+}
+
+// RegisterStream is RegisterCollection for collections read sequentially, such as cursors or channels.
+// For every query, the rows of the sequence returned by fn are converted chunk by chunk as DuckDB reads them,
+// reading at most a chunk of rows ahead rather than holding all of them in memory. A chunk holds the rows ready
+// once its first row arrives, so queries do not wait for rows they do not need. A channel is served by
+// a sequence ranging over it:
+//
+//	duckgo.RegisterStream(conn, "events", func() iter.Seq[Event] {
+//		return func(yield func(Event) bool) {
+//			for e := range bus.Subscribe(ctx) {
+//				if !yield(e) {
+//					return
+//				}
+//			}
+//		}
+//	})
+//
+// A query waiting for a row fails once the context passed to QueryContext or ExecContext is done. DuckDB does not
+// tell table functions that a query stopped reading them early, e.g. because of a LIMIT, so the sequence of
+// such a query is stopped once that context is done; queries run with a context that is never done, such as
+// context.Background(), stop it when the garbage collector finds it unreachable. A stopped sequence returns
+// when it next yields.
+func RegisterStream[T any](conn *sql.Conn, name string, fn func() iter.Seq[T]) error {
+	columns, err := rowColumns(reflect.TypeFor[[]T]())
+	if err != nil {
+		return fmt.Errorf("duckgo: stream %s: %w", name, err)
+	}
+	return registerView(conn, name, columns, func(ctx context.Context) (duckdb.ChunkTableSource, error) {
+		seq, err := callGo(fn)
+		if err != nil {
+			return nil, err
+		}
+		return newSeqSource(ctx, columns, seq), nil
+	})
+}
+
+// registerView registers the table function name, with the given columns, reading the rows of the source
+// returned by open for every scan, and the temporary view name selecting all of them. open is passed the context
+// of the query.
+func registerView(conn *sql.Conn, name string, columns []column, open func(context.Context) (duckdb.ChunkTableSource, error)) error {
+	// DuckDB keeps the first table function registered under a name, and temporary views shadow tables
+	var registered, exists bool
+	err := conn.QueryRowContext(context.Background(), "SELECT "+
+		"EXISTS (SELECT 1 FROM duckdb_functions() WHERE function_type = 'table' AND lower(function_name) = lower($1)), "+
+		"EXISTS (SELECT 1 FROM duckdb_tables() WHERE NOT internal AND lower(table_name) = lower($1) "+
+		"UNION ALL SELECT 1 FROM duckdb_views() WHERE NOT internal AND lower(view_name) = lower($1))", name).
+		Scan(&registered, &exists)
+	if err != nil {
+		return fmt.Errorf("duckgo: collection %s: %w", name, err)
+	}
+	if registered {
+		return fmt.Errorf("duckgo: collection %s: a table function with this name is already registered", name)
+	}
+	if exists {
+		return fmt.Errorf("duckgo: collection %s: a table or view with this name already exists", name)
+	}
+	err = duckdb.RegisterTableUDF(conn, name, duckdb.ChunkTableFunction{
+		BindArgumentsContext: func(ctx context.Context, named map[string]any, args ...any) (duckdb.ChunkTableSource, error) {
+			return &lazySource{ctx: ctx, name: name, columns: columns, open: open}, nil
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("duckgo: collection %s: %w", name, err)
+	}
+	ident := convert.QuoteIdent(name)
+	if _, err := conn.ExecContext(context.Background(),
+		"CREATE TEMP VIEW "+ident+" AS SELECT * FROM "+ident+"()"); err != nil {
+		return fmt.Errorf("duckgo: collection %s: %w", name, err)
+	}
+	return nil
+}
+
+// callGo returns the result of fn, or its panic as an error, because panics cannot cross DuckDB.
+func callGo[R any](fn func() R) (r R, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic in Go callback: %v", p)
+		}
+	}()
+	return fn(), nil
+}
+
+// lazySource opens the source of a collection when the scan reads its first chunk, because DuckDB
+// also binds table functions when it does not scan them, e.g. to create the view.
+type lazySource struct {
+	ctx     context.Context // Context of the query
+	name    string
+	columns []column
+	open    func(context.Context) (duckdb.ChunkTableSource, error)
+	source  duckdb.ChunkTableSource
+}
+
+func (s *lazySource) ColumnInfos() []duckdb.ColumnInfo {
+	return columnInfos(s.columns)
+}
+
+func (s *lazySource) Cardinality() *duckdb.CardinalityInfo {
+	return nil // Unknown until the collection is read
+}
+
+func (s *lazySource) Init() {}
+
+func (s *lazySource) FillChunk(chunk duckdb.DataChunk) error {
+	if s.source == nil {
+		source, err := s.open(s.ctx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.name, err)
+		}
+		s.source = source
+	}
+	return s.source.FillChunk(chunk)
+}
+
+// seqSource returns the elements of a sequence of structs as rows.
+type seqSource[T any] struct {
+	ctx       context.Context // Context of the query
+	columns   []column
+	rows      *puller[T]
+	stopAfter func() bool // Unregisters the stop at the end of the query context
+	projected []bool
+}
+
+func newSeqSource[T any](ctx context.Context, columns []column, seq iter.Seq[T]) *seqSource[T] {
+	rows := pull(seq, duckdb.GetDataChunkCapacity())
+	s := &seqSource[T]{ctx: ctx, columns: columns, rows: rows}
+	// DuckDB does not tell table functions that a query stopped reading them. The whole query runs before
+	// QueryContext returns, so the scan is over once its context is done; without one, the sequence is
+	// stopped with the unreachable source.
+	s.stopAfter = context.AfterFunc(ctx, rows.stop)
+	runtime.AddCleanup(s, func(rows *puller[T]) { rows.stop() }, rows)
+	return s
+}
+
+func (s *seqSource[T]) ColumnInfos() []duckdb.ColumnInfo {
+	return columnInfos(s.columns)
+}
+
+func (s *seqSource[T]) Cardinality() *duckdb.CardinalityInfo {
+	return nil // Unknown until the sequence ends
+}
+
+func (s *seqSource[T]) Init() {}
+
+func (s *seqSource[T]) FillChunk(chunk duckdb.DataChunk) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic converting Go rows: %v", r)
+		}
+		if err != nil {
+			s.finish()
+		}
+	}()
+	if s.projected == nil {
+		if s.projected, err = projectedColumns(&chunk, s.columns); err != nil {
+			return err
+		}
+	}
+	// Only the first row is waited for: a chunk with fewer rows than its capacity does not end the scan
+	n := 0
+	for n < duckdb.GetDataChunkCapacity() {
+		v, ok, done, err := s.rows.next(s.ctx, n == 0)
+		if err != nil {
+			return err
+		}
+		if done {
+			s.finish()
+			break
+		}
+		if !ok {
+			break
+		}
+		if err := setRow(&chunk, n, s.columns, s.projected, reflect.ValueOf(&v).Elem()); err != nil {
+			return err
+		}
+		n++
+	}
+	return chunk.SetSize(n)
+}
+
+// finish stops the sequence, ending the scan.
+func (s *seqSource[T]) finish() {
+	s.rows.stop()
+	s.stopAfter()
+}
+
+// puller runs a sequence on its own goroutine and reads its elements ahead: the coroutines of iter.Pull must be
+// resumed from the OS thread they were created on, while DuckDB reads table functions from any of its threads,
+// and a sequence waiting for its next element could not be interrupted.
+type puller[T any] struct {
+	rows    chan T
+	err     error // Panic of the sequence, set before rows is closed
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// pull starts reading seq, up to ahead elements before they are taken by next.
+func pull[T any](seq iter.Seq[T], ahead int) *puller[T] {
+	p := &puller[T]{rows: make(chan T, ahead), stopped: make(chan struct{})}
+	go func() {
+		defer close(p.rows)
+		defer func() {
+			if r := recover(); r != nil {
+				p.err = fmt.Errorf("panic reading Go rows: %v", r)
+			}
+		}()
+		for v := range seq {
+			select {
+			case p.rows <- v:
+			case <-p.stopped:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// next returns the next element, with ok set. If wait is set, it waits for one until ctx is done, returning
+// the error of ctx, or the sequence is stopped; otherwise it returns at once if none is ready. done reports
+// that no element will follow, because the sequence ended, failed with err, or was stopped.
+func (p *puller[T]) next(ctx context.Context, wait bool) (v T, ok, done bool, err error) {
+	if !wait {
+		select {
+		case v, open := <-p.rows:
+			return p.received(v, open)
+		default:
+			return v, false, false, nil
+		}
+	}
+	select {
+	case v, open := <-p.rows:
+		return p.received(v, open)
+	case <-p.stopped:
+		return v, false, true, nil
+	case <-ctx.Done():
+		return v, false, true, ctx.Err()
+	}
+}
+
+// received returns the result of next for an element received from rows.
+func (p *puller[T]) received(v T, open bool) (T, bool, bool, error) {
+	if !open {
+		return v, false, true, p.err
+	}
+	return v, true, false, nil
+}
+
+// stop makes the sequence return when it next yields. It may be called several times.
+func (p *puller[T]) stop() {
+	p.once.Do(func() { close(p.stopped) })
+}
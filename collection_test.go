@@ -0,0 +1,256 @@
+package duckgo
+
+import (
+	"context"
+	"database/sql"
+	"iter"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterCollection(t *testing.T) {
+	_, conn := openConnector(t)
+	users := []user{{ID: 1, Name: "ada"}, {ID: 2, Name: "bob"}}
+	calls := 0
+	require.NoError(t, RegisterCollection(conn, "sessions", func() []user { calls++; return users }))
+
+	var n int
+	var names string
+	require.NoError(t, conn.QueryRowContext(context.Background(),
+		"SELECT count(*), string_agg(name, ',' ORDER BY id) FROM sessions").Scan(&n, &names))
+	require.Equal(t, 2, n)
+	require.Equal(t, "ada,bob", names)
+
+	// The collection is read again by every query
+	before := calls
+	users = append(users, user{ID: 3, Name: "cy"})
+	require.NoError(t, conn.QueryRowContext(context.Background(), "SELECT max(id) FROM sessions").Scan(&n))
+	require.Equal(t, 3, n)
+	require.Equal(t, before+1, calls)
+
+	// Large collections span several chunks
+	many := make([]*user, 5000)
+	for i := range many {
+		many[i] = &user{ID: int64(i)}
+	}
+	many[10] = nil
+	require.NoError(t, RegisterCollection(conn, "Many Users", func() []*user { return many }))
+	var sum, nulls int
+	require.NoError(t, conn.QueryRowContext(context.Background(),
+		`SELECT sum(id)::BIGINT, count(*) FILTER (WHERE id IS NULL) FROM "Many Users"`).Scan(&sum, &nulls))
+	require.Equal(t, 5000*4999/2-10, sum)
+	require.Equal(t, 1, nulls)
+
+	err := RegisterCollection(conn, "broken", func() []user { panic("cache closed") })
+	require.NoError(t, err)
+	err = conn.QueryRowContext(context.Background(), "SELECT count(*) FROM broken").Scan(&n)
+	require.ErrorContains(t, err, "panic in Go callback: cache closed")
+
+	require.ErrorContains(t, RegisterCollection(conn, "Sessions", func() []user { return nil }), "already registered")
+	_, err = conn.ExecContext(context.Background(), "CREATE TABLE accounts (id INTEGER)")
+	require.NoError(t, err)
+	require.ErrorContains(t, RegisterCollection(conn, "ACCOUNTS", func() []user { return nil }), "already exists")
+	require.ErrorContains(t, RegisterCollection(conn, "ints", func() []int { return nil }), "rows must be structs")
+}
+
+func TestRegisterCollectionFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "collections.duckdb")
+	open := func() (*sql.DB, *sql.Conn) {
+		db, err := sql.Open("duckdb", path)
+		require.NoError(t, err)
+		conn, err := db.Conn(context.Background())
+		require.NoError(t, err)
+		return db, conn
+	}
+	users := []user{{ID: 1, Name: "ada"}}
+
+	db, conn := open()
+	require.NoError(t, RegisterCollection(conn, "sessions", func() []user { return users }))
+	var n int
+	require.NoError(t, conn.QueryRowContext(context.Background(), "SELECT count(*) FROM sessions").Scan(&n))
+	require.Equal(t, 1, n)
+	// The view belongs to conn, other connections read the table function
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM sessions()").Scan(&n))
+	require.Equal(t, 1, n)
+	require.Error(t, db.QueryRow("SELECT count(*) FROM sessions").Scan(&n))
+	_, err := conn.ExecContext(context.Background(), "CREATE TABLE kept AS SELECT * FROM sessions")
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	require.NoError(t, db.Close())
+
+	// The reopened file has no view left over, and the collection can be registered again
+	db, conn = open()
+	defer db.Close()
+	defer conn.Close()
+	require.NoError(t, conn.QueryRowContext(context.Background(),
+		"SELECT count(*) FROM duckdb_views() WHERE NOT internal").Scan(&n))
+	require.Zero(t, n)
+	require.NoError(t, conn.QueryRowContext(context.Background(), "SELECT count(*) FROM kept").Scan(&n))
+	require.Equal(t, 1, n)
+	users = append(users, user{ID: 2, Name: "bob"})
+	require.NoError(t, RegisterCollection(conn, "sessions", func() []user { return users }))
+	require.NoError(t, conn.QueryRowContext(context.Background(), "SELECT count(*) FROM sessions").Scan(&n))
+	require.Equal(t, 2, n)
+}
+
+// probeSource records the columns projected into a scan.
+type probeSource struct {
+	columns   []column
+	projected []bool
+	err       error
+}
+
+func (s *probeSource) ColumnInfos() []duckdb.ColumnInfo     { return columnInfos(s.columns) }
+func (s *probeSource) Cardinality() *duckdb.CardinalityInfo { return nil }
+func (s *probeSource) Init()                                {}
+func (s *probeSource) FillChunk(chunk duckdb.DataChunk) error {
+	s.projected, s.err = projectedColumns(&chunk, s.columns)
+	return chunk.SetSize(0)
+}
+
+func TestProjectedColumns(t *testing.T) {
+	_, conn := openConnector(t)
+	columns, err := structColumns(reflect.TypeFor[user]())
+	require.NoError(t, err)
+	source := &probeSource{columns: columns}
+	require.NoError(t, duckdb.RegisterTableUDF(conn, "probe", duckdb.ChunkTableFunction{
+		BindArguments: func(named map[string]any, args ...any) (duckdb.ChunkTableSource, error) {
+			return source, nil
+		},
+	}))
+	tests := []struct {
+		query string
+		want  []bool
+	}{
+		{"SELECT name FROM probe()", []bool{false, true, false, false, false, false}},
+		{"SELECT Tags, id FROM probe() WHERE Email IS NULL", []bool{true, false, true, false, true, false}},
+		{"SELECT * FROM probe()", []bool{true, true, true, true, true, true}},
+	}
+	for _, tt := range tests {
+		rows, err := conn.QueryContext(context.Background(), tt.query)
+		require.NoError(t, err)
+		require.NoError(t, rows.Close())
+		require.NoError(t, source.err, tt.query)
+		require.Equal(t, tt.want, source.projected, tt.query)
+	}
+
+	// A projected column whose probe cannot be written is an error rather than left NULL
+	columns[1].zero = []int{1}
+	rows, err := conn.QueryContext(context.Background(), "SELECT id FROM probe()")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	require.NoError(t, source.err)
+	rows, err = conn.QueryContext(context.Background(), "SELECT name FROM probe()")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	require.ErrorContains(t, source.err, "column name")
+}
+
+func TestRegisterStream(t *testing.T) {
+	_, conn := openConnector(t)
+	stopped := 0
+	events := func(n int) func() iter.Seq[user] {
+		return func() iter.Seq[user] {
+			return func(yield func(user) bool) {
+				defer func() { stopped++ }()
+				for i := range n {
+					if !yield(user{ID: int64(i), Name: "e"}) {
+						return
+					}
+				}
+			}
+		}
+	}
+	require.NoError(t, RegisterStream(conn, "events", events(5000)))
+	var n, sum int
+	require.NoError(t, conn.QueryRowContext(context.Background(),
+		"SELECT count(*), sum(id)::BIGINT FROM events").Scan(&n, &sum))
+	require.Equal(t, 5000, n)
+	require.Equal(t, 5000*4999/2, sum)
+	require.NotZero(t, stopped)
+
+	ch := make(chan user, 3)
+	for i := range 3 {
+		ch <- user{ID: int64(i + 1), Name: "c"}
+	}
+	close(ch)
+	require.NoError(t, RegisterStream(conn, "chan_events", func() iter.Seq[user] {
+		return func(yield func(user) bool) {
+			for e := range ch {
+				if !yield(e) {
+					return
+				}
+			}
+		}
+	}))
+	require.NoError(t, conn.QueryRowContext(context.Background(), "SELECT sum(id)::BIGINT FROM chan_events").Scan(&sum))
+	require.Equal(t, 6, sum)
+
+	// A query reading part of an endless stream stops it once its context is done
+	ticksStopped := make(chan struct{})
+	require.NoError(t, RegisterStream(conn, "ticks", func() iter.Seq[user] {
+		return func(yield func(user) bool) {
+			defer close(ticksStopped)
+			for i := 0; yield(user{ID: int64(i)}); i++ {
+			}
+		}
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, conn.QueryRowContext(ctx, "SELECT count(*) FROM (SELECT * FROM ticks LIMIT 3)").Scan(&n))
+	require.Equal(t, 3, n)
+	select {
+	case <-ticksStopped:
+		t.Fatal("stream stopped before the query context is done")
+	default:
+	}
+	cancel()
+	select {
+	case <-ticksStopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not stopped once the query context is done")
+	}
+
+	// Chunks hold the rows that are ready, so a LIMIT is satisfied by an idle channel
+	idle := make(chan user, 20)
+	for i := range 10 {
+		idle <- user{ID: int64(i)}
+	}
+	require.NoError(t, RegisterStream(conn, "idle_events", func() iter.Seq[user] {
+		return func(yield func(user) bool) {
+			for e := range idle {
+				if !yield(e) {
+					return
+				}
+			}
+		}
+	}))
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, conn.QueryRowContext(ctx, "SELECT count(*) FROM (SELECT * FROM idle_events LIMIT 10)").Scan(&n))
+	require.Equal(t, 10, n)
+
+	// A query waiting for rows of an idle channel fails once its context is done
+	for i := range 3 {
+		idle <- user{ID: int64(i)}
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := conn.QueryRowContext(ctx, "SELECT count(*) FROM (SELECT * FROM idle_events LIMIT 10)").Scan(&n)
+	require.ErrorContains(t, err, "deadline exceeded")
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	require.NoError(t, RegisterStream(conn, "failing", func() iter.Seq[user] {
+		return func(yield func(user) bool) {
+			yield(user{ID: 1})
+			panic("subscriber gone")
+		}
+	}))
+	err = conn.QueryRowContext(context.Background(), "SELECT count(*) FROM failing").Scan(&n)
+	require.ErrorContains(t, err, "subscriber gone")
+}
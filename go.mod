@@ -6,7 +6,11 @@ require (
 	github.com/duckdb/duckdb-go/v2 v2.10502.0
 	github.com/goccy/go-json v0.10.6
 	github.com/goplus/ixgo v1.0.5
+	github.com/mattn/go-runewidth v0.0.16
+	github.com/peterh/liner v1.2.2
 	github.com/stretchr/testify v1.11.1
+	github.com/timandy/routine v1.1.6
+	golang.org/x/tools v0.44.0
 )
 
 require (
@@ -30,7 +34,7 @@ require (
 	github.com/pierrec/lz4/v4 v4.1.26 // indirect
 	github.com/pmezard/go-difflib v1.0.1-0.20181226105442-5d4384ee4fb2 // indirect
 	github.com/qiniu/x v1.17.0 // indirect
-	github.com/timandy/routine v1.1.6 // indirect
+	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/visualfc/funcval v0.1.4 // indirect
 	github.com/visualfc/goembed v0.3.4 // indirect
 	github.com/visualfc/xtype v0.3.0 // indirect
@@ -40,7 +44,6 @@ require (
 	golang.org/x/sync v0.20.0 // indirect
 	golang.org/x/sys v0.43.0 // indirect
 	golang.org/x/telemetry v0.0.0-20260414141209-fac6e1c83189 // indirect
-	golang.org/x/tools v0.44.0 // indirect
 	golang.org/x/xerrors v0.0.0-20240903120638-7835f813f4da // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
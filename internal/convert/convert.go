@@ -1,9 +1,13 @@
-package udf
+// Package convert maps Go types to DuckDB types and converts values between them.
+// It implements the type mapping of UDFs in the udf package, which the duckgo package reuses for tables
+// and the types package exports.
+package convert
 
 import (
 	"database/sql/driver"
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/duckdb/duckdb-go/v2"
@@ -70,7 +74,7 @@ func toOrderedMap(sourceVal driver.Value) (orderedMap, bool) {
 	return nil, false
 }
 
-// goTypeToDuckDBTypeInfo converts a Go reflect.Type to a DuckDB TypeInfo.
+// TypeInfoOf converts a Go reflect.Type to a DuckDB TypeInfo.
 // This function returns duckdb.TypeInfo, which encapsulates the logical description of the type.
 //
 // Supported Go types include:
@@ -87,7 +91,7 @@ func toOrderedMap(sourceVal driver.Value) (orderedMap, bool) {
 // Pointer types (like *struct, *map, *time.Time) will be automatically dereferenced once.
 // Unsupported types include channels, functions, interfaces, and slices (except []byte).
 // Empty structs (with no exported fields) are also not supported.
-func goTypeToDuckDBTypeInfo(rt reflect.Type) (duckdb.TypeInfo, error) {
+func TypeInfoOf(rt reflect.Type) (duckdb.TypeInfo, error) {
 	// Handle pointer dereferencing.
 	if rt.Kind() == reflect.Pointer {
 		elemType := rt.Elem()
@@ -158,7 +162,7 @@ func goTypeToDuckDBTypeInfo(rt reflect.Type) (duckdb.TypeInfo, error) {
 				continue
 			}
 			fieldName := field.Name // Use Go field name directly for DuckDB struct field name
-			fieldTypeInfo, err := goTypeToDuckDBTypeInfo(field.Type)
+			fieldTypeInfo, err := TypeInfoOf(field.Type)
 			if err != nil {
 				return nil, fmt.Errorf("error converting field '%s' of struct %s: %w", fieldName, rt.Name(), err)
 			}
@@ -187,14 +191,14 @@ func goTypeToDuckDBTypeInfo(rt reflect.Type) (duckdb.TypeInfo, error) {
 		keyType := rt.Key()
 		valType := rt.Elem()
 
-		keyTypeInfo, err := goTypeToDuckDBTypeInfo(keyType)
+		keyTypeInfo, err := TypeInfoOf(keyType)
 		if err != nil {
 			return nil, fmt.Errorf("error converting map key type %s for UDF: %w", keyType.String(), err)
 		}
 		// TODO: Add validation for DuckDB map key types (e.g., not complex types themselves)
-		// For now, assume goTypeToDuckDBTypeInfo handles basic unsupported types.
+		// For now, assume TypeInfoOf handles basic unsupported types.
 
-		valTypeInfo, err := goTypeToDuckDBTypeInfo(valType)
+		valTypeInfo, err := TypeInfoOf(valType)
 		if err != nil {
 			return nil, fmt.Errorf("error converting map value type %s for UDF: %w", valType.String(), err)
 		}
@@ -228,7 +232,61 @@ func goTypeToDuckDBTypeInfo(rt reflect.Type) (duckdb.TypeInfo, error) {
 	return typeInfo, nil
 }
 
-// convertToReflectValue converts a value from DuckDB (via driver.Value) to a reflect.Value expected by the user function.
+// SQLType returns the SQL spelling of the DuckDB type TypeInfoOf maps rt to, such as INTEGER or
+// STRUCT("ID" BIGINT, "Tags" MAP(VARCHAR, INTEGER)), for statements creating tables or casting values.
+func SQLType(rt reflect.Type) (string, error) {
+	if _, err := TypeInfoOf(rt); err != nil {
+		return "", err
+	}
+	return sqlTypeName(rt, true), nil
+}
+
+// sqlTypeName spells the type of a Go type supported by TypeInfoOf, dereferencing one pointer if deref is set.
+func sqlTypeName(rt reflect.Type, deref bool) string {
+	if deref && rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+	if rt.PkgPath() == "time" && rt.Name() == "Time" {
+		return "TIMESTAMP"
+	}
+	switch rt.Kind() {
+	case reflect.Int, reflect.Int16, reflect.Int8, reflect.Int32:
+		return "INTEGER"
+	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint8:
+		return "UINTEGER"
+	case reflect.Int64:
+		return "BIGINT"
+	case reflect.Uint64:
+		return "UBIGINT"
+	case reflect.Float32:
+		return "FLOAT"
+	case reflect.Float64:
+		return "DOUBLE"
+	case reflect.String:
+		return "VARCHAR"
+	case reflect.Bool:
+		return "BOOLEAN"
+	case reflect.Slice:
+		return "BLOB"
+	case reflect.Map:
+		return "MAP(" + sqlTypeName(rt.Key(), true) + ", " + sqlTypeName(rt.Elem(), true) + ")"
+	default: // Struct
+		var fields []string
+		for i := 0; i < rt.NumField(); i++ {
+			if field := rt.Field(i); field.IsExported() {
+				fields = append(fields, QuoteIdent(field.Name)+" "+sqlTypeName(field.Type, true))
+			}
+		}
+		return "STRUCT(" + strings.Join(fields, ", ") + ")"
+	}
+}
+
+// QuoteIdent quotes a SQL identifier, such as a table, column or STRUCT field name.
+func QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// FromDuckDB converts a value from DuckDB (via driver.Value) to a reflect.Value expected by the user function.
 //
 // This function supports the following type conversions:
 // - SQL NULL -> Go nil (for pointer, slice, map, channel, function, and interface types)
@@ -241,13 +299,14 @@ func goTypeToDuckDBTypeInfo(rt reflect.Type) (duckdb.TypeInfo, error) {
 // - SQL TIMESTAMP (microsecond value) -> Go time.Time
 // - SQL STRUCT -> Go struct (field names must match)
 // - SQL MAP -> Go map (key and value types must match)
+// - SQL LIST -> Go slice (elements are converted like values)
 //
 // Special restrictions:
 // - No implicit numeric to string conversion allowed (prevents unexpected data loss)
 // - No implicit numeric to boolean conversion allowed
 // - Struct conversion requires all exported fields of the target struct to have corresponding values in the source map
 // - Map conversion requires key and value types that can be converted to the target map's key and value types
-func convertToReflectValue(sourceVal driver.Value, targetType reflect.Type) (reflect.Value, error) {
+func FromDuckDB(sourceVal driver.Value, targetType reflect.Type) (reflect.Value, error) {
 	if sourceVal == nil {
 		// If the target is a pointer, slice, map, chan, func, or interface, a nil sourceVal maps to a nil reflect.Value of that type.
 		// For structs, it maps to a zero struct.
@@ -313,7 +372,7 @@ func convertToReflectValue(sourceVal driver.Value, targetType reflect.Type) (ref
 			if !exists {
 				return reflect.Value{}, fmt.Errorf("field '%s' missing in source map from DuckDB STRUCT for target Go struct %s", fieldSpec.Name, targetType.Name())
 			}
-			convertedFieldVal, err := convertToReflectValue(valFromMap, fieldSpec.Type)
+			convertedFieldVal, err := FromDuckDB(valFromMap, fieldSpec.Type)
 			if err != nil {
 				return reflect.Value{}, fmt.Errorf("error converting field '%s' for Go struct %s: %w", fieldSpec.Name, targetType.Name(), err)
 			}
@@ -335,7 +394,7 @@ func convertToReflectValue(sourceVal driver.Value, targetType reflect.Type) (ref
 		for i := range keys {
 			k := keys[i]
 			v := values[i]
-			convertedKey, err := convertToReflectValue(k, goMapKeyType)
+			convertedKey, err := FromDuckDB(k, goMapKeyType)
 			if err != nil {
 				return reflect.Value{}, fmt.Errorf("error converting map key for target Go map %s: %w", goMapType.String(), err)
 			}
@@ -347,7 +406,7 @@ func convertToReflectValue(sourceVal driver.Value, targetType reflect.Type) (ref
 					k, k, goMapKeyType.String())
 			}
 
-			convertedValue, err := convertToReflectValue(v, goMapElemType)
+			convertedValue, err := FromDuckDB(v, goMapElemType)
 			if err != nil {
 				return reflect.Value{}, fmt.Errorf("error converting map value for key '%v' for target Go map %s: %w",
 					convertedKey.Interface(), goMapType.String(), err)
@@ -356,13 +415,28 @@ func convertToReflectValue(sourceVal driver.Value, targetType reflect.Type) (ref
 			newGoMap.SetMapIndex(convertedKey, convertedValue)
 		}
 		return newGoMap, nil
+
+	case reflect.Slice: // []byte from BLOB is assignable
+		srcList, ok := sourceVal.([]any)
+		if !ok {
+			break // e.g. string to []byte
+		}
+		newSlice := reflect.MakeSlice(targetType, len(srcList), len(srcList))
+		for i, elem := range srcList {
+			convertedElem, err := FromDuckDB(elem, targetType.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("error converting list element %d for target Go slice %s: %w", i, targetType.String(), err)
+			}
+			newSlice.Index(i).Set(convertedElem)
+		}
+		return newSlice, nil
 	}
 
 	// Handle cases where targetType is a pointer to a basic type (e.g. *string, *int)
 	if targetType.Kind() == reflect.Pointer {
 		elemType := targetType.Elem()
 		// Attempt to convert sourceVal to the element type first
-		convertedElemVal, err := convertToReflectValue(sourceVal, elemType)
+		convertedElemVal, err := FromDuckDB(sourceVal, elemType)
 		if err != nil {
 			return reflect.Value{}, fmt.Errorf("error converting to element type %s for pointer target %s: %w", elemType.String(), targetType.String(), err)
 		}
@@ -394,13 +468,13 @@ func convertToReflectValue(sourceVal driver.Value, targetType reflect.Type) (ref
 		reflect.TypeOf(sourceVal).String(), sourceReflectVal.Type().String(), sourceVal, targetType.String())
 }
 
-// convertGoToDuckDBValue converts a Go return value from a user-defined function to a DuckDB-compatible value.
-// This is the inverse of convertToReflectValue, handling the conversion from Go types back to DuckDB driver values.
+// ToDuckDB converts a Go return value from a user-defined function to a DuckDB-compatible value.
+// This is the inverse of FromDuckDB, handling the conversion from Go types back to DuckDB driver values.
 //
 // Currently handles:
 //   - Go map[K]V -> duckdb.OrderedMap (DuckDB requires OrderedMap for MAP return values)
 //   - All other types are returned as-is (DuckDB driver handles basic types natively)
-func convertGoToDuckDBValue(val any) (any, error) {
+func ToDuckDB(val any) (any, error) {
 	if val == nil {
 		return nil, nil
 	}
@@ -0,0 +1,161 @@
+package duckgo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"iter"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ma6174/duckgo/internal/convert"
+)
+
+// Querier runs queries. It is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Query runs a query and returns its rows as values of type T, converted like the arguments of UDFs:
+// a STRUCT value is scanned into a Go struct with its field names, a MAP into a Go map, a LIST into a slice,
+// and NULL into a nil pointer, map or slice, or a zero struct. Other NULL values and lossy conversions
+// such as numbers into strings are errors.
+//
+// If T is a struct, or a pointer to a struct, each column is stored in the field named like the column,
+// case-insensitively, or tagged duckdb:"name", as for the rows of Scans. Columns without a field are
+// an error; fields without a column keep their zero value. Otherwise, the query must return a single column:
+//
+//	type Session struct {
+//		ID    int64 `duckdb:"id"`
+//		User  *string
+//		Attrs map[string]string
+//	}
+//	sessions, err := duckgo.Query[Session](ctx, db, "SELECT id, user, attrs FROM sessions WHERE id > ?", 10)
+//	names, err := duckgo.Query[string](ctx, db, "SELECT name FROM users")
+func Query[T any](ctx context.Context, q Querier, query string, args ...any) ([]T, error) {
+	var result []T
+	for v, err := range QueryIter[T](ctx, q, query, args...) {
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+// QueryIter is Query returning the rows one by one, as they are read. Iteration stops after an error,
+// which is yielded with the zero value of T. Stopping the iteration closes the rows.
+//
+//	for s, err := range duckgo.QueryIter[Session](ctx, db, "SELECT * FROM sessions") {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func QueryIter[T any](ctx context.Context, q Querier, query string, args ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer rows.Close()
+		names, err := rows.Columns()
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		scanner, err := newRowScanner(reflect.TypeFor[T](), names)
+		if err != nil {
+			yield(zero, fmt.Errorf("duckgo: %w", err))
+			return
+		}
+		for rows.Next() {
+			var v T
+			if err := scanner.scan(rows, reflect.ValueOf(&v).Elem()); err != nil {
+				yield(zero, fmt.Errorf("duckgo: %w", err))
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+// rowScanner stores the columns of rows into values of a Go type.
+type rowScanner struct {
+	names  []string
+	fields []int // Struct field index of each column, or nil to store the only column in the value
+	ptr    bool  // Whether the value is a pointer to the struct
+	values []any
+	dest   []any
+}
+
+func newRowScanner(t reflect.Type, names []string) (*rowScanner, error) {
+	s := &rowScanner{names: names, values: make([]any, len(names)), dest: make([]any, len(names))}
+	for i := range s.values {
+		s.dest[i] = &s.values[i]
+	}
+	structType := t
+	if t.Kind() == reflect.Pointer {
+		structType, s.ptr = t.Elem(), true
+	}
+	if structType.Kind() != reflect.Struct || structType == reflect.TypeOf(time.Time{}) {
+		if len(names) != 1 {
+			return nil, fmt.Errorf("query returns %d columns, which cannot be scanned into %s", len(names), t)
+		}
+		s.ptr = false
+		return s, nil
+	}
+	columns, err := columnFields(structType)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]int, len(columns))
+	for _, c := range columns {
+		byName[strings.ToLower(c.name)] = c.index
+	}
+	s.fields = make([]int, len(names))
+	for i, name := range names {
+		index, ok := byName[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("column %s has no field in %s", name, structType)
+		}
+		s.fields[i] = index
+	}
+	return s, nil
+}
+
+// scan stores the current row of rows into v.
+func (s *rowScanner) scan(rows *sql.Rows, v reflect.Value) error {
+	if err := rows.Scan(s.dest...); err != nil {
+		return err
+	}
+	if s.fields == nil {
+		converted, err := convert.FromDuckDB(s.values[0], v.Type())
+		if err != nil {
+			return fmt.Errorf("column %s: %w", s.names[0], err)
+		}
+		v.Set(converted)
+		return nil
+	}
+	if s.ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	for i, value := range s.values {
+		field := v.Field(s.fields[i])
+		converted, err := convert.FromDuckDB(value, field.Type())
+		if err != nil {
+			return fmt.Errorf("column %s: %w", s.names[i], err)
+		}
+		field.Set(converted)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package duckgo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type session struct {
+	ID      int64 `duckdb:"id"`
+	User    *string
+	Started time.Time
+	Attrs   map[string]int32
+	Tags    []string
+	Client  struct {
+		Name    string
+		Version *int32
+	}
+	Scores []*float64
+	Note   string `duckdb:"-"`
+}
+
+func TestQuery(t *testing.T) {
+	db, err := sql.Open("duckdb", "")
+	require.NoError(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	sessions, err := Query[session](ctx, db, `SELECT * FROM (VALUES
+		(1, 'ada', TIMESTAMP '2024-01-02 03:04:05', MAP {'a': 1}, ['x', 'y'], {'Name': 'cli', 'Version': 2}, [1.5::DOUBLE, NULL]),
+		(2, NULL, TIMESTAMP '2024-01-03 00:00:00', NULL, NULL, {'Name': 'web', 'Version': NULL}, [])
+	) t(ID, "user", started, attrs, tags, client, scores) ORDER BY id`)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	ada := sessions[0]
+	require.Equal(t, int64(1), ada.ID)
+	require.Equal(t, "ada", *ada.User)
+	require.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), ada.Started)
+	require.Equal(t, map[string]int32{"a": 1}, ada.Attrs)
+	require.Equal(t, []string{"x", "y"}, ada.Tags)
+	require.Equal(t, "cli", ada.Client.Name)
+	require.Equal(t, int32(2), *ada.Client.Version)
+	require.Len(t, ada.Scores, 2)
+	require.Equal(t, 1.5, *ada.Scores[0])
+	require.Nil(t, ada.Scores[1])
+	second := sessions[1]
+	require.Nil(t, second.User)
+	require.Nil(t, second.Attrs)
+	require.Nil(t, second.Tags)
+	require.Nil(t, second.Client.Version)
+	require.Empty(t, second.Scores)
+
+	// Pointers to structs, placeholders and fields without a column
+	ptrs, err := Query[*session](ctx, db, "SELECT ?::BIGINT AS id", 7)
+	require.NoError(t, err)
+	require.Equal(t, int64(7), ptrs[0].ID)
+	require.Nil(t, ptrs[0].User)
+
+	names, err := Query[string](ctx, db, "SELECT unnest(['a', 'b'])")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, names)
+	lists, err := Query[[]int](ctx, db, "SELECT [1, 2] UNION ALL SELECT NULL")
+	require.NoError(t, err)
+	require.Equal(t, [][]int{{1, 2}, nil}, lists)
+
+	// The conversions of UDF arguments apply
+	_, err = Query[string](ctx, db, "SELECT 42")
+	require.ErrorContains(t, err, "column 42")
+	_, err = Query[int](ctx, db, "SELECT NULL::INTEGER AS n")
+	require.ErrorContains(t, err, "cannot convert nil")
+	_, err = Query[session](ctx, db, "SELECT 1 AS id, 'x' AS note")
+	require.ErrorContains(t, err, "column note has no field")
+	_, err = Query[int](ctx, db, "SELECT 1, 2")
+	require.ErrorContains(t, err, "query returns 2 columns")
+	_, err = Query[int](ctx, db, "SELECT * FROM missing_table")
+	require.ErrorContains(t, err, "missing_table")
+}
+
+func TestQueryIter(t *testing.T) {
+	_, conn := openConnector(t)
+	ctx := context.Background()
+	var ids []int64
+	for v, err := range QueryIter[int64](ctx, conn, "SELECT range FROM range(10000)") {
+		require.NoError(t, err)
+		ids = append(ids, v)
+		if len(ids) == 3 {
+			break
+		}
+	}
+	require.Equal(t, []int64{0, 1, 2}, ids)
+
+	// The connection is usable again once the iteration stopped
+	var n int
+	require.NoError(t, conn.QueryRowContext(ctx, "SELECT 1").Scan(&n))
+
+	var errs int
+	for _, err := range QueryIter[int32](ctx, conn, "SELECT CASE WHEN range < 2 THEN range::INTEGER END FROM range(5)") {
+		if err != nil {
+			errs++
+		}
+	}
+	require.Equal(t, 1, errs)
+}
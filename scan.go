@@ -0,0 +1,267 @@
+// Package duckgo makes Go data queryable from DuckDB. Functions written in Go are provided by the udf
+// and script packages; this package serves Go values as tables, appends them to tables and reads query
+// results into them, with the same mapping of Go types to SQL types as UDFs.
+package duckgo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+// ScanFunctionName is the table function that Scans rewrites the tables it resolves to.
+const ScanFunctionName = "duckgo_scan"
+
+// ErrUnknownTable is wrapped by the error of duckgo_scan for a name no longer resolved by its Scans.
+var ErrUnknownTable = errors.New("no Go data registered for table")
+
+// Scans resolves table names in queries to rows produced by Go callbacks, using DuckDB replacement scans:
+// after Install, a query reading a table that does not exist in the database, such as
+// SELECT * FROM my_cache or SELECT * FROM 'kv://users', reads the rows returned by the callback
+// registered for the name, or for the scheme of the name. Callbacks run once per query.
+//
+// Rows are slices of structs, or of pointers to structs, with a column per exported field, typed like
+// the STRUCT fields of UDFs. Columns are named after the fields, or their duckdb tag; fields tagged
+// duckdb:"-" are skipped. Nil pointers, including nil rows, are NULL.
+//
+// A Scans is safe for concurrent use; callbacks may be registered before or after Install.
+type Scans struct {
+	mu      sync.RWMutex
+	tables  map[string]reflect.Value                  // Callbacks by lower-case table name
+	schemes map[string]func(path string) (any, error) // Handlers by lower-case scheme
+}
+
+// NewScans returns an empty Scans.
+func NewScans() *Scans {
+	return &Scans{
+		tables:  make(map[string]reflect.Value),
+		schemes: make(map[string]func(path string) (any, error)),
+	}
+}
+
+// Register makes queries of the table name read the rows returned by fn, which must be a function
+// without parameters returning a slice of structs, optionally followed by an error:
+//
+//	scans.Register("sessions", func() []Session { return cache.Sessions() })
+//
+// Table names are case-insensitive. Registering a name again replaces its callback.
+func (s *Scans) Register(name string, fn any) error {
+	fv := reflect.ValueOf(fn)
+	ft := fv.Type()
+	if ft.Kind() != reflect.Func || ft.NumIn() != 0 || ft.NumOut() < 1 || ft.NumOut() > 2 ||
+		ft.NumOut() == 2 && ft.Out(1) != reflect.TypeFor[error]() {
+		return fmt.Errorf("duckgo: table %s: callback must be a func() []T or func() ([]T, error), but is %s", name, ft)
+	}
+	if _, err := rowColumns(ft.Out(0)); err != nil {
+		return fmt.Errorf("duckgo: table %s: %w", name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tables[strings.ToLower(name)] = fv
+	return nil
+}
+
+// HandleScheme makes queries of tables named scheme://path, such as SELECT * FROM 'kv://users',
+// read the rows returned by fn for the path, e.g. "users". fn must return a slice of structs,
+// whose type may differ between paths. Schemes are case-insensitive.
+func (s *Scans) HandleScheme(scheme string, fn func(path string) (any, error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schemes[strings.ToLower(scheme)] = fn
+}
+
+// Install registers the replacement scan with the connector of a database and the duckgo_scan table function
+// with conn, a connection to it. Replacement scans only apply to tables that do not exist in the database,
+// so tables and views shadow the names registered with s.
+func (s *Scans) Install(connector *duckdb.Connector, conn *sql.Conn) error {
+	err := duckdb.RegisterTableUDF(conn, ScanFunctionName, duckdb.ChunkTableFunction{
+		Config: duckdb.TableFunctionConfig{Arguments: []duckdb.TypeInfo{varcharInfo}},
+		BindArguments: func(named map[string]any, args ...any) (duckdb.ChunkTableSource, error) {
+			name := args[0].(string)
+			rows, err := s.rows(name)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			return newSliceSource(rows)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	duckdb.RegisterReplacementScan(connector, s.replace)
+	return nil
+}
+
+// replace is the replacement scan callback, rewriting resolved table names to duckgo_scan.
+func (s *Scans) replace(tableName string) (string, []any, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.tables[strings.ToLower(tableName)]; ok {
+		return ScanFunctionName, []any{tableName}, nil
+	}
+	if scheme, _, ok := strings.Cut(tableName, "://"); ok && s.schemes[strings.ToLower(scheme)] != nil {
+		return ScanFunctionName, []any{tableName}, nil
+	}
+	return "", nil, nil // Not ours, DuckDB reports the missing table
+}
+
+// rows calls the callback resolving name. Panics of the callback are returned as errors,
+// because they cannot cross DuckDB.
+func (s *Scans) rows(name string) (rows reflect.Value, err error) {
+	s.mu.RLock()
+	fn, isTable := s.tables[strings.ToLower(name)]
+	var handler func(path string) (any, error)
+	scheme, path, hasScheme := strings.Cut(name, "://")
+	if hasScheme {
+		handler = s.schemes[strings.ToLower(scheme)]
+	}
+	s.mu.RUnlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in Go callback: %v", r)
+		}
+	}()
+	switch {
+	case isTable:
+		out := fn.Call(nil)
+		if len(out) == 2 && !out[1].IsNil() {
+			return reflect.Value{}, out[1].Interface().(error)
+		}
+		return out[0], nil
+	case handler != nil:
+		v, err := handler(path)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if v == nil {
+			return reflect.Value{}, errors.New("Go callback returned no rows")
+		}
+		return reflect.ValueOf(v), nil
+	default:
+		return reflect.Value{}, ErrUnknownTable
+	}
+}
+
+// varcharInfo is the type of the argument of duckgo_scan.
+var varcharInfo = func() duckdb.TypeInfo {
+	info, err := duckdb.NewTypeInfo(duckdb.TYPE_VARCHAR)
+	if err != nil {
+		panic(err)
+	}
+	return info
+}()
+
+// rowColumns returns the columns of a table whose rows are a slice of type t.
+func rowColumns(t reflect.Type) ([]column, error) {
+	if t.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("rows must be a slice of structs, but got %s", t)
+	}
+	elem := t.Elem()
+	if elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	return structColumns(elem)
+}
+
+// sliceSource returns the elements of a slice of structs as rows.
+type sliceSource struct {
+	columns   []column
+	rows      reflect.Value
+	next      int
+	projected []bool // Columns read by the query, known after the first chunk
+}
+
+func newSliceSource(rows reflect.Value) (*sliceSource, error) {
+	columns, err := rowColumns(rows.Type())
+	if err != nil {
+		return nil, err
+	}
+	return &sliceSource{columns: columns, rows: rows}, nil
+}
+
+func (s *sliceSource) ColumnInfos() []duckdb.ColumnInfo {
+	return columnInfos(s.columns)
+}
+
+func (s *sliceSource) Cardinality() *duckdb.CardinalityInfo {
+	return &duckdb.CardinalityInfo{Cardinality: uint(s.rows.Len()), Exact: true}
+}
+
+func (s *sliceSource) Init() {}
+
+func (s *sliceSource) FillChunk(chunk duckdb.DataChunk) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic converting Go rows: %v", r)
+		}
+	}()
+	if s.projected == nil {
+		if s.projected, err = projectedColumns(&chunk, s.columns); err != nil {
+			return err
+		}
+	}
+	n := min(s.rows.Len()-s.next, duckdb.GetDataChunkCapacity())
+	for i := range n {
+		if err := setRow(&chunk, i, s.columns, s.projected, s.rows.Index(s.next+i)); err != nil {
+			return err
+		}
+	}
+	s.next += n
+	return chunk.SetSize(n)
+}
+
+// projectedColumns reports which columns of a table function are read by the query.
+// DuckDB pushes projections down into table functions; DataChunk ignores writes to the other columns
+// but fails to read them, which is probed on the first row before it is filled. The probe writes
+// a value rather than NULL, because DataChunk cannot mark a NULL row valid again, and reads it back
+// rather than uninitialized memory. Only projected columns can fail the write, which is returned.
+func projectedColumns(chunk *duckdb.DataChunk, columns []column) ([]bool, error) {
+	projected := make([]bool, len(columns))
+	for i, c := range columns {
+		if err := chunk.SetValue(i, 0, c.zero); err != nil {
+			return nil, fmt.Errorf("column %s: %w", c.name, err)
+		}
+		_, err := chunk.GetValue(i, 0)
+		projected[i] = err == nil
+	}
+	return projected, nil
+}
+
+// setRow sets the projected columns of row rowIdx of chunk from the fields of the struct, or pointer
+// to struct, v; fields of other columns are not converted. Rows are written through DataChunk.SetValue,
+// as for UDF results, because Row cannot hold NULL.
+func setRow(chunk *duckdb.DataChunk, rowIdx int, columns []column, projected []bool, v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			for i := range columns {
+				if !projected[i] {
+					continue
+				}
+				if err := chunk.SetValue(i, rowIdx, nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		v = v.Elem()
+	}
+	for i, c := range columns {
+		if !projected[i] {
+			continue
+		}
+		value, err := columnValue(v, c)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", c.name, err)
+		}
+		if err := chunk.SetValue(i, rowIdx, value); err != nil {
+			return fmt.Errorf("column %s: %w", c.name, err)
+		}
+	}
+	return nil
+}
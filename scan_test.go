@@ -0,0 +1,144 @@
+package duckgo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type user struct {
+	ID      int64  `duckdb:"id"`
+	Name    string `duckdb:"name"`
+	Email   *string
+	Joined  time.Time
+	Tags    map[string]int32
+	Address struct{ City string }
+	secret  string
+	Ignored string `duckdb:"-"`
+}
+
+// openConnector opens an in-memory database, returning its connector and a connection.
+func openConnector(t *testing.T) (*duckdb.Connector, *sql.Conn) {
+	t.Helper()
+	connector, err := duckdb.NewConnector("", nil)
+	require.NoError(t, err)
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { db.Close() })
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return connector, conn
+}
+
+func TestScans(t *testing.T) {
+	connector, conn := openConnector(t)
+	email := "ada@example.com"
+	users := []user{
+		{ID: 1, Name: "ada", Email: &email, Joined: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Tags: map[string]int32{"admin": 1}},
+		{ID: 2, Name: "bob", secret: "x", Ignored: "y"},
+	}
+	calls := 0
+	scans := NewScans()
+	require.NoError(t, scans.Register("My_Users", func() []user { calls++; return users }))
+	require.NoError(t, scans.Install(connector, conn))
+	scans.HandleScheme("kv", func(path string) (any, error) {
+		switch path {
+		case "users":
+			return []*user{&users[1], nil}, nil
+		case "broken":
+			return nil, errors.New("storage offline")
+		case "panic":
+			panic("corrupt page")
+		default:
+			return []string{path}, nil
+		}
+	})
+
+	var n int
+	var names string
+	require.NoError(t, conn.QueryRowContext(context.Background(),
+		"SELECT count(*), string_agg(name, ',' ORDER BY id) FROM my_users").Scan(&n, &names))
+	require.Equal(t, 2, n)
+	require.Equal(t, "ada,bob", names)
+
+	var emailCol sql.NullString
+	var joined time.Time
+	var tags any
+	var city string
+	require.NoError(t, conn.QueryRowContext(context.Background(),
+		"SELECT Email, Joined, Tags, Address.City FROM my_users WHERE id = 1").Scan(&emailCol, &joined, &tags, &city))
+	require.Equal(t, email, emailCol.String)
+	require.True(t, joined.Equal(users[0].Joined))
+	tagMap, ok := tags.(duckdb.OrderedMap)
+	require.True(t, ok, "MAP column scanned as %T", tags)
+	admin, _ := tagMap.Get("admin")
+	require.Equal(t, int32(1), admin)
+	require.Empty(t, city)
+
+	rows, err := conn.QueryContext(context.Background(), "DESCRIBE my_users")
+	require.NoError(t, err)
+	var columns []string
+	for rows.Next() {
+		var name, typ string
+		var null, key, def, extra sql.NullString
+		require.NoError(t, rows.Scan(&name, &typ, &null, &key, &def, &extra))
+		columns = append(columns, name+" "+typ)
+	}
+	require.NoError(t, rows.Err())
+	require.Equal(t, []string{"id BIGINT", "name VARCHAR", "Email VARCHAR", "Joined TIMESTAMP",
+		"Tags MAP(VARCHAR, INTEGER)", "Address STRUCT(City VARCHAR)"}, columns)
+
+	// Callbacks run for every query
+	before := calls
+	require.NoError(t, conn.QueryRowContext(context.Background(), "SELECT count(*) FROM my_users").Scan(&n))
+	require.Equal(t, before+1, calls)
+
+	var nullNames int
+	require.NoError(t, conn.QueryRowContext(context.Background(),
+		"SELECT count(*) FILTER (WHERE name IS NULL) FROM 'kv://users'").Scan(&nullNames))
+	require.Equal(t, 1, nullNames)
+
+	err = conn.QueryRowContext(context.Background(), "SELECT * FROM 'kv://broken'").Scan(&n)
+	require.ErrorContains(t, err, "storage offline")
+	err = conn.QueryRowContext(context.Background(), "SELECT * FROM 'kv://panic'").Scan(&n)
+	require.ErrorContains(t, err, "panic in Go callback: corrupt page")
+	err = conn.QueryRowContext(context.Background(), "SELECT * FROM 'kv://strings'").Scan(&n)
+	require.ErrorContains(t, err, "rows must be structs")
+	err = conn.QueryRowContext(context.Background(), "SELECT * FROM unknown_table").Scan(&n)
+	require.ErrorContains(t, err, "unknown_table")
+
+	// Tables of the database take precedence
+	_, err = conn.ExecContext(context.Background(), "CREATE TABLE my_users AS SELECT 42 AS id")
+	require.NoError(t, err)
+	require.NoError(t, conn.QueryRowContext(context.Background(), "SELECT id FROM my_users").Scan(&n))
+	require.Equal(t, 42, n)
+}
+
+func TestScansRegisterErrors(t *testing.T) {
+	scans := NewScans()
+	tests := []struct {
+		fn     any
+		errMsg string
+	}{
+		{func() user { return user{} }, "rows must be a slice of structs"},
+		{func(int) []user { return nil }, "callback must be a func() []T"},
+		{func() ([]user, int) { return nil, 0 }, "callback must be a func() []T"},
+		{func() []struct{ x int } { return nil }, "has no exported fields"},
+		{func() []struct{ C chan int } { return nil }, "field C"},
+		{func() []struct {
+			A int `duckdb:"x"`
+			B int `duckdb:"X"`
+		} {
+			return nil
+		}, `several columns named "X"`},
+	}
+	for _, tt := range tests {
+		require.ErrorContains(t, scans.Register("t", tt.fn), tt.errMsg)
+	}
+	require.NoError(t, scans.Register("t", func() ([]*user, error) { return nil, nil }))
+}
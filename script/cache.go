@@ -0,0 +1,190 @@
+package script
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/goplus/ixgo"
+	"github.com/goplus/ixgo/xgobuild"
+)
+
+// Cache keeps compiled scripts so that loading identical source again skips parsing, type checking,
+// SSA construction and package initialization. A Cache is safe for concurrent use and can be shared
+// by Loaders registering functions in many databases; enable it with WithCache.
+//
+// Entries are keyed by a hash of the script content and file extension, the ixgo version, the set of
+// packages registered with ixgo, whether step counting is enabled and the interpreter pool size, so a change
+// to any of them compiles the script again. Databases loading the same script through one Cache share a single
+// interpreter, or interpreter pool, including its package-level variables.
+//
+// With a directory, the Cache also stores the Go code generated from XGo scripts on disk, so that
+// new processes skip the XGo compiler. Go scripts are not stored on disk, because they need no such step.
+// The generated code does not depend on step counting or the pool size, so it is shared by all Loaders.
+type Cache struct {
+	dir string
+
+	mu      sync.Mutex
+	entries map[string]*compiledScript
+
+	hits     atomic.Int64
+	misses   atomic.Int64
+	diskHits atomic.Int64
+}
+
+// CacheStats reports how often a Cache was used.
+type CacheStats struct {
+	Entries  int   // Number of compiled scripts held in memory
+	Hits     int64 // Loads served from memory
+	Misses   int64 // Loads that had to compile the script
+	DiskHits int64 // Misses that reused Go code generated by an earlier process
+}
+
+// compiledScript holds the initialized interpreters of one script: a single one, or the independent
+// instances of an interpreter pool.
+type compiledScript struct {
+	interps []*ixgo.Interp
+	free    chan int // Indexes of idle instances, nil without a pool
+}
+
+// NewCache creates a Cache. If dir is not empty, Go code generated from XGo scripts is also stored
+// in dir and reused across processes; the directory is created when needed.
+func NewCache(dir string) *Cache {
+	return &Cache{
+		dir:     dir,
+		entries: make(map[string]*compiledScript),
+	}
+}
+
+// Stats returns the current usage counters of the Cache.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+	return CacheStats{
+		Entries:  entries,
+		Hits:     c.hits.Load(),
+		Misses:   c.misses.Load(),
+		DiskHits: c.diskHits.Load(),
+	}
+}
+
+// load returns the compiled script for the content of filename, compiling it with compile on a miss.
+func (c *Cache) load(filename string, content []byte, stepCounting bool, poolSize int, compile func(filename string, src any) (*compiledScript, error)) (*compiledScript, error) {
+	source := sourceKey(filepath.Ext(filename), content)
+	key := fmt.Sprintf("%s steps=%t pool=%d", source, stepCounting, poolSize)
+
+	c.mu.Lock()
+	cs, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		c.hits.Add(1)
+		return cs, nil
+	}
+	c.misses.Add(1)
+
+	var src any = content
+	if c.dir != "" && isXGoFile(filename) {
+		goSrc, err := c.generatedGoSource(source, filename, content)
+		if err != nil {
+			return nil, err
+		}
+		// Load the generated code as Go, keeping the directory of the original file for embeds and error messages.
+		filename, src = strings.TrimSuffix(filename, filepath.Ext(filename))+".go", goSrc
+	}
+
+	cs, err := compile(filename, src)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if existing, ok := c.entries[key]; ok {
+		return existing, nil // Compiled concurrently by another load; keep a single interpreter per script
+	}
+	c.entries[key] = cs
+	return cs, nil
+}
+
+// generatedGoSource returns the Go code generated from an XGo script, reading it from the cache
+// directory if an earlier process already generated it.
+func (c *Cache) generatedGoSource(key, filename string, content []byte) ([]byte, error) {
+	path := filepath.Join(c.dir, key+".go")
+	if data, err := os.ReadFile(path); err == nil {
+		c.diskHits.Add(1)
+		return data, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("reading script cache: %w", err)
+	}
+
+	data, err := xgobuild.BuildFile(ixgo.NewContext(0), filename, content)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating script cache directory: %w", err)
+	}
+	// Write to a temporary file first so that concurrent processes never read a partial file.
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("writing script cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("writing script cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("writing script cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("writing script cache: %w", err)
+	}
+	return data, nil
+}
+
+// sourceKey hashes everything that influences the Go code generated from a script, naming its file on disk.
+// Compiled scripts are also keyed by the interpreter options.
+func sourceKey(ext string, content []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "ixgo %s\n", ixgoVersion())
+	fmt.Fprintf(h, "packages %s\n", strings.Join(ixgo.PackageList(), ","))
+	fmt.Fprintf(h, "ext %s\n", ext)
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ixgoVersion returns the version of the ixgo module linked into the binary.
+var ixgoVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/goplus/ixgo" {
+			if dep.Replace != nil {
+				return dep.Replace.Path + "@" + dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "unknown"
+})
+
+// isXGoFile reports whether filename is compiled by the XGo compiler before interpretation.
+func isXGoFile(filename string) bool {
+	switch filepath.Ext(filename) {
+	case ".xgo", ".gop":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,185 @@
+package script
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+// CatalogTable is the table in which a Loader created WithCatalog records the scripts it loads.
+// Each row holds one script:
+//
+//	hash      VARCHAR    -- SHA-256 of the source text, hex encoded
+//	filename  VARCHAR    -- base name the script was loaded as; its extension selects the language
+//	source    VARCHAR    -- the source text
+//	functions VARCHAR[]  -- SQL names of the functions registered from the script
+//	aliases   MAP(VARCHAR, VARCHAR) -- SQL name to script function name, for functions registered under another name
+//	options   VARCHAR    -- Loader options as JSON, e.g. {"max_steps":10000,"pool_size":4}
+//	loaded_at TIMESTAMP
+const CatalogTable = "duckgo.udf_sources"
+
+const createCatalogSQL = `CREATE SCHEMA IF NOT EXISTS duckgo;
+CREATE TABLE IF NOT EXISTS ` + CatalogTable + ` (
+	hash      VARCHAR NOT NULL,
+	filename  VARCHAR NOT NULL,
+	source    VARCHAR NOT NULL,
+	functions VARCHAR[] NOT NULL,
+	aliases   MAP(VARCHAR, VARCHAR),
+	options   VARCHAR,
+	loaded_at TIMESTAMP DEFAULT current_timestamp,
+	PRIMARY KEY (hash, filename)
+)`
+
+// catalogOptions are the Loader options stored with a script, so that it is restored with the same limits
+// and interpreter pool. Options configuring how files are found or cached, and udf.Option values, which are
+// functions, are not stored.
+type catalogOptions struct {
+	MaxSteps int64 `json:"max_steps,omitempty"`
+	PoolSize int   `json:"pool_size,omitempty"`
+}
+
+// RestoreFromCatalog registers all functions recorded in the catalog table of db by Loaders created WithCatalog,
+// e.g. right after opening a database file that was created by another application.
+// Scripts are loaded from the stored source text, so the original files need not exist.
+// The opts configure the Loader used for restoring; a stored step budget and interpreter pool size take precedence
+// over WithMaxSteps and WithInterpreterPool. Options given WithUDFOptions are not stored, so those of opts apply.
+// A database without a catalog table has nothing to restore.
+func RestoreFromCatalog(db *sql.DB, opts ...Option) error {
+	exists, err := catalogExists(db)
+	if err != nil || !exists {
+		return err
+	}
+	rows, err := db.Query("SELECT filename, source, functions, aliases, options FROM " + CatalogTable + " ORDER BY loaded_at")
+	if err != nil {
+		return fmt.Errorf("reading UDF catalog: %w", err)
+	}
+	defer rows.Close()
+
+	type entry struct {
+		filename, source string
+		specs            []string
+		options          catalogOptions
+	}
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		var functions duckdb.Composite[[]string]
+		var aliases duckdb.Map
+		var options sql.NullString
+		if err := rows.Scan(&e.filename, &e.source, &functions, &aliases, &options); err != nil {
+			return fmt.Errorf("reading UDF catalog: %w", err)
+		}
+		if options.Valid {
+			if err := json.Unmarshal([]byte(options.String), &e.options); err != nil {
+				return fmt.Errorf("reading UDF catalog: options of %s: %w", e.filename, err)
+			}
+		}
+		for _, name := range functions.Get() {
+			if fn, ok := aliases[name]; ok {
+				name = name + "=" + fmt.Sprint(fn)
+			}
+			e.specs = append(e.specs, name)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reading UDF catalog: %w", err)
+	}
+	rows.Close()
+
+	for _, e := range entries {
+		l := NewLoader(opts...)
+		l.options.catalog = false // Already recorded
+		if e.options.MaxSteps > 0 {
+			l.options.maxSteps = e.options.MaxSteps
+		}
+		if e.options.PoolSize > 0 {
+			l.options.poolSize = e.options.PoolSize
+		}
+		if err := l.addIXGoUDF(db, e.filename, e.source, e.specs...); err != nil {
+			return fmt.Errorf("restoring UDFs from catalog: %w", err)
+		}
+	}
+	return nil
+}
+
+// catalogExists reports whether db has a catalog table.
+func catalogExists(db *sql.DB) (bool, error) {
+	var n int
+	err := db.QueryRow("SELECT count(*) FROM duckdb_tables() WHERE schema_name = 'duckgo' AND table_name = 'udf_sources'").Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("reading UDF catalog: %w", err)
+	}
+	return n > 0, nil
+}
+
+// recordInCatalog adds the functions registered from a script to the catalog table, creating it if necessary.
+// Loading a script again merges the new functions into its existing row.
+func (l *Loader) recordInCatalog(conn *sql.Conn, filename string, content []byte, funcSpecs []string) error {
+	ctx := context.Background()
+	if _, err := conn.ExecContext(ctx, createCatalogSQL); err != nil {
+		return fmt.Errorf("creating UDF catalog: %w", err)
+	}
+
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	filename = filepath.Base(filename)
+
+	var functions []string
+	aliases := duckdb.Map{}
+	var existing duckdb.Composite[[]string]
+	var existingAliases duckdb.Map
+	err := conn.QueryRowContext(ctx, "SELECT functions, aliases FROM "+CatalogTable+" WHERE hash = ? AND filename = ?", hash, filename).
+		Scan(&existing, &existingAliases)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return fmt.Errorf("reading UDF catalog: %w", err)
+	default:
+		functions = existing.Get()
+		for k, v := range existingAliases {
+			aliases[k] = v
+		}
+	}
+	for _, spec := range funcSpecs {
+		sqlName, funcName := parseFuncSpec(spec)
+		if !slices.Contains(functions, sqlName) {
+			functions = append(functions, sqlName)
+		}
+		if sqlName != funcName {
+			aliases[sqlName] = funcName
+		} else {
+			delete(aliases, sqlName)
+		}
+	}
+
+	options, err := json.Marshal(catalogOptions{MaxSteps: l.options.maxSteps, PoolSize: l.options.poolSize})
+	if err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, "INSERT OR REPLACE INTO "+CatalogTable+
+		" (hash, filename, source, functions, aliases, options) VALUES (?, ?, ?, ?, ?, ?)",
+		hash, filename, string(content), functions, aliases, string(options))
+	if err != nil {
+		return fmt.Errorf("writing UDF catalog: %w", err)
+	}
+	return nil
+}
+
+// parseFuncSpec splits a function name passed to the Loader into the SQL name and the name of the script function.
+// "name" registers the script function name under the same SQL name, "alias=name" registers it as alias.
+func parseFuncSpec(spec string) (sqlName, funcName string) {
+	if sqlName, funcName, ok := strings.Cut(spec, "="); ok {
+		return strings.TrimSpace(sqlName), strings.TrimSpace(funcName)
+	}
+	return spec, spec
+}
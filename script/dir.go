@@ -0,0 +1,64 @@
+package script
+
+import (
+	"database/sql"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/goplus/ixgo"
+	"github.com/ma6174/duckgo/udf"
+)
+
+// AddIXGoUDFFromDir loads every .go and .xgo file in dir (not including subdirectories and _test.go files)
+// and registers all functions they declare as UDFs in DuckDB, applying the options of the Loader.
+// Functions named main or init and functions whose signature cannot be used as a UDF, such as helpers
+// taking channels, are skipped. It returns the names of the registered functions.
+func (l *Loader) AddIXGoUDFFromDir(db *sql.DB, dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var registered []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if ext := filepath.Ext(name); ext != ".go" && !isXGoFile(name) {
+			continue
+		}
+		path, err := l.resolvePath(filepath.Join(dir, name))
+		if err != nil {
+			return registered, err
+		}
+		names, err := l.addSelectedIXGoUDF(db, path, nil, udfFuncNames)
+		registered = append(registered, names...)
+		if err != nil {
+			return registered, err
+		}
+	}
+	return registered, nil
+}
+
+// udfFuncNames returns the sorted names of the package-level functions of the script that can be registered as UDFs.
+func udfFuncNames(interp *ixgo.Interp) []string {
+	var names []string
+	for name, member := range interp.MainPkg().Members {
+		if member.Token() != token.FUNC || name == "main" || name == "init" || !token.IsIdentifier(name) {
+			continue
+		}
+		fn, ok := interp.GetFunc(name)
+		if !ok {
+			continue
+		}
+		if _, err := udf.BuildScalarUDF(fn); err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,189 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"reflect"
+	"regexp"
+	"strconv"
+
+	"github.com/goplus/ixgo"
+	"github.com/ma6174/duckgo/udf"
+	"github.com/timandy/routine"
+)
+
+// ErrFuncNotFound is wrapped by the LoadError returned when a requested function is not defined by the script.
+var ErrFuncNotFound = errors.New("function not found")
+
+// LoadError is returned when a script cannot be loaded or one of its functions cannot be registered as a UDF.
+// Use errors.As to inspect it; the underlying error is available through errors.Unwrap.
+type LoadError struct {
+	File string // Script file name, "main.go" or "main.xgo" for source text
+	Line int    // Line of the error in File, 0 if unknown
+	Col  int    // Column of the error in File, 0 if unknown
+	Func string // Name of the function being registered, empty for errors affecting the whole script
+	Err  error
+}
+
+func (e *LoadError) Error() string {
+	pos := e.File
+	if e.Line > 0 {
+		pos += ":" + strconv.Itoa(e.Line)
+		if e.Col > 0 {
+			pos += ":" + strconv.Itoa(e.Col)
+		}
+	}
+	if e.Func != "" {
+		return fmt.Sprintf("%s: func %s: %v", pos, e.Func, e.Err)
+	}
+	if e.Line > 0 {
+		// Compiler errors already start with their position, so only the message is repeated.
+		return fmt.Sprintf("%s: %s", pos, errorMessage(e.Err))
+	}
+	return fmt.Sprintf("%s: %v", pos, e.Err)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// RuntimeError is the panic value of a script function that failed while running as a UDF,
+// so the error reported by the UDF names the function and, if known, the position in the script.
+// The position is known for explicit panics and errors detected by the interpreter, but not for
+// errors raised by the Go runtime such as an integer division by zero.
+type RuntimeError struct {
+	Func string         // Name of the script function called by the UDF
+	Pos  token.Position // Position of the failing statement, invalid if unknown
+	Err  error          // The panic value, converted to an error if necessary
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("script function %s failed at %s: %v", e.Func, e.Pos, e.Err)
+	}
+	return fmt.Sprintf("script function %s failed: %v", e.Func, e.Err)
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// positionPrefix matches the "file:line:col: " prefix of compiler errors that do not expose their position.
+var positionPrefix = regexp.MustCompile(`^(.+?):(\d+):(\d+): `)
+
+// newLoadError wraps err, adding the position of the first compiler error if there is one.
+func newLoadError(filename, funcName string, err error) *LoadError {
+	le := &LoadError{File: filename, Func: funcName, Err: err}
+	var pos token.Position
+	var list scanner.ErrorList
+	var typeErr types.Error
+	switch {
+	case errors.As(err, &list) && len(list) > 0:
+		pos = list[0].Pos
+	case errors.As(err, &typeErr):
+		pos = typeErr.Fset.Position(typeErr.Pos)
+	default:
+		// XGo compiler errors carry their position only in the message.
+		if m := positionPrefix.FindStringSubmatch(err.Error()); m != nil {
+			pos.Filename = m[1]
+			pos.Line, _ = strconv.Atoi(m[2])
+			pos.Column, _ = strconv.Atoi(m[3])
+		}
+	}
+	if pos.Line > 0 {
+		if pos.Filename != "" {
+			le.File = pos.Filename
+		}
+		le.Line, le.Col = pos.Line, pos.Column
+	}
+	return le
+}
+
+// errorMessage returns the message of a compiler error without its position prefix.
+func errorMessage(err error) string {
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		msg := list[0].Msg
+		if len(list) > 1 {
+			msg += fmt.Sprintf(" (and %d more errors)", len(list)-1)
+		}
+		return msg
+	}
+	var typeErr types.Error
+	if errors.As(err, &typeErr) {
+		return typeErr.Msg
+	}
+	msg := err.Error()
+	if m := positionPrefix.FindStringIndex(msg); m != nil {
+		return msg[m[1]:]
+	}
+	return msg
+}
+
+// panicSite is the most recent panic the interpreter reported on a goroutine.
+type panicSite struct {
+	err error
+	pos token.Position
+}
+
+// lastPanic holds the panic site of the script function call running on the current goroutine.
+var lastPanic = routine.NewThreadLocal[*panicSite]()
+
+// recordPanics makes the interpreter report the position of panics in code loaded into ctx.
+// The panic value is left unchanged, so recover in the script still sees the original value.
+func recordPanics(ctx *ixgo.Context) {
+	ctx.SetPanic(func(info *ixgo.PanicInfo) {
+		lastPanic.Set(&panicSite{err: info.Error, pos: info.Position()})
+	})
+}
+
+// wrapScriptFunc wraps the interpreted function fn so that panics are re-raised as a *RuntimeError naming
+// funcName and the position in the script. If maxSteps > 0, every call also gets a fresh budget of maxSteps.
+// The wrapper has the same signature as fn and can be passed to udf.BuildScalarUDF in its place.
+func wrapScriptFunc(fn any, funcName string, maxSteps int64) any {
+	fnVal := reflect.ValueOf(fn)
+	return reflect.MakeFunc(fnVal.Type(), func(args []reflect.Value) []reflect.Value {
+		if maxSteps > 0 {
+			outer := currentBudget.Get()
+			currentBudget.Set(&stepBudget{funcName: funcName, maxSteps: maxSteps})
+			defer currentBudget.Set(outer)
+		}
+		lastPanic.Set(nil)
+		defer func() {
+			if r := recover(); r != nil {
+				panic(runtimeError(funcName, r))
+			}
+		}()
+		if fnVal.Type().IsVariadic() {
+			return fnVal.CallSlice(args)
+		}
+		return fnVal.Call(args)
+	}).Interface()
+}
+
+// runtimeError converts the value recovered from a script function into the value to panic with.
+// Limit violations and errors of nested script functions are passed on unchanged.
+func runtimeError(funcName string, r any) any {
+	err, ok := r.(error)
+	if !ok {
+		err = fmt.Errorf("%v", r)
+	}
+	var nested *RuntimeError
+	if errors.Is(err, udf.ErrLimitExceeded) || errors.As(err, &nested) {
+		return r
+	}
+	re := &RuntimeError{Func: funcName, Err: err}
+	if site := lastPanic.Get(); site != nil && sameError(site.err, err) {
+		re.Pos = site.pos
+	}
+	return re
+}
+
+// sameError reports whether the error recorded by the panic hook is the one that reached the wrapper.
+// Panic values need not be comparable, so the errors are compared by type and message.
+func sameError(recorded, err error) bool {
+	return recorded != nil && reflect.TypeOf(recorded) == reflect.TypeOf(err) && recorded.Error() == err.Error()
+}
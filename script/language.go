@@ -0,0 +1,35 @@
+package script
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+)
+
+// Script languages accepted by Loader.AddIXGoUDFFromSourceLang and create_ixgo_udf_lang.
+const (
+	LanguageGo  = "go"
+	LanguageXGo = "xgo"
+)
+
+// sourceFilename returns the file name under which source text in language is loaded.
+// The interpreter selects the parser by file extension.
+func sourceFilename(language string) (string, error) {
+	switch language {
+	case LanguageGo:
+		return "main.go", nil
+	case LanguageXGo:
+		return "main.xgo", nil
+	default:
+		return "", fmt.Errorf("unsupported script language %q, expected %q or %q", language, LanguageGo, LanguageXGo)
+	}
+}
+
+// withPackageClause prepends "package main" to src if it does not start with a package clause,
+// so that short snippets consisting only of imports and functions can be loaded.
+func withPackageClause(src string) string {
+	if _, err := parser.ParseFile(token.NewFileSet(), "", src, parser.PackageClauseOnly); err == nil {
+		return src
+	}
+	return "package main\n\n" + src
+}
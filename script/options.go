@@ -0,0 +1,91 @@
+package script
+
+import (
+	"github.com/ma6174/duckgo/udf"
+)
+
+// loaderOption contains optional parameters for NewLoader.
+type loaderOption struct {
+	udfOptions        []udf.Option
+	maxSteps          int64
+	allowedRoots      []string
+	allowedExtensions []string
+	cache             *Cache
+	catalog           bool
+	poolSize          int
+}
+
+// Option configures a Loader.
+type Option func(*loaderOption)
+
+// WithUDFOptions sets the options passed to udf.BuildScalarUDF for every function the Loader registers,
+// e.g. udf.WithTimeout or udf.WithLimitPolicy.
+func WithUDFOptions(opts ...udf.Option) Option {
+	return func(o *loaderOption) {
+		o.udfOptions = append(o.udfOptions, opts...)
+	}
+}
+
+// WithMaxSteps limits the number of interpreter steps a single call of a script function may take.
+// A step is counted for every variable and function reference the interpreter evaluates, so loops,
+// recursion and called helper functions all consume the budget of the calling UDF.
+// A call that runs out of steps is aborted with an error wrapping udf.ErrLimitExceeded,
+// which is handled according to the udf.LimitPolicy of the function.
+// Counting steps slows down interpretation, so it is disabled by default (n <= 0).
+func WithMaxSteps(n int64) Option {
+	return func(o *loaderOption) {
+		o.maxSteps = n
+	}
+}
+
+// WithAllowedRoots confines loading script files to the given root directories.
+// Relative filenames are looked up in each root in order; absolute filenames must lie inside one of the roots.
+// Symlinks are resolved before checking, so a link inside a root that points elsewhere is rejected as well.
+// Files outside all roots are rejected with an error wrapping ErrPathNotAllowed.
+// Loading from source text is not affected. By default, any path is accepted.
+func WithAllowedRoots(dirs ...string) Option {
+	return func(o *loaderOption) {
+		o.allowedRoots = append(o.allowedRoots, dirs...)
+	}
+}
+
+// WithAllowedExtensions only accepts script files whose extension (including the dot, e.g. ".go" or ".xgo")
+// is one of exts. Other files are rejected with an error wrapping ErrPathNotAllowed.
+// By default, any extension is accepted.
+func WithAllowedExtensions(exts ...string) Option {
+	return func(o *loaderOption) {
+		o.allowedExtensions = append(o.allowedExtensions, exts...)
+	}
+}
+
+// WithCache makes the Loader reuse compiled scripts from c instead of interpreting identical source again.
+// Share one Cache between the Loaders of all databases that load the same scripts. By default, nothing is cached.
+func WithCache(c *Cache) Option {
+	return func(o *loaderOption) {
+		o.cache = c
+	}
+}
+
+// WithCatalog makes the Loader record every script it loads, with the registered functions, the step budget and
+// the interpreter pool size, in the CatalogTable of the database, so that RestoreFromCatalog can register them
+// again after the database is reopened.
+// Only the source text is stored, so a database file carries its functions even to hosts without the script files.
+func WithCatalog() Option {
+	return func(o *loaderOption) {
+		o.catalog = true
+	}
+}
+
+// WithInterpreterPool interprets every script n times and runs each call of its functions on an idle instance,
+// so that concurrent calls never share the package-level variables of the script. Scripts keeping state in
+// global maps, for example, are then safe to call from DuckDB's worker threads without locks.
+// At most n calls of the functions of a script run at the same time; further calls wait for an idle instance.
+//
+// Every instance has its own globals, so state written by one call is only seen by later calls that happen
+// to run on the same instance. Package initialization runs once per instance. By default (n <= 0),
+// a script is interpreted once and all calls share its interpreter.
+func WithInterpreterPool(n int) Option {
+	return func(o *loaderOption) {
+		o.poolSize = n
+	}
+}
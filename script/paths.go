@@ -0,0 +1,109 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrPathNotAllowed is wrapped by the errors returned when a script file lies outside the allowed
+// root directories or does not have one of the allowed extensions.
+var ErrPathNotAllowed = errors.New("script path not allowed")
+
+// resolvePath checks filename against the allowed roots and extensions of the Loader
+// and returns the path the script should be loaded from.
+//
+// Without configured roots, filename is returned unchanged (after the extension check).
+// With roots, relative filenames are looked up in each root in order, and absolute filenames must lie inside one of them.
+// All symlinks are resolved before the check, so links pointing out of a root are rejected,
+// and the resolved path is returned so that the checked file is the one that gets loaded.
+func (l *Loader) resolvePath(filename string) (string, error) {
+	if !l.hasAllowedExtension(filename) {
+		return "", fmt.Errorf("%w: %q does not have one of the extensions %v", ErrPathNotAllowed, filename, l.options.allowedExtensions)
+	}
+	if len(l.options.allowedRoots) == 0 {
+		return filename, nil
+	}
+
+	roots := make([]string, len(l.options.allowedRoots))
+	for i, root := range l.options.allowedRoots {
+		resolved, err := resolveRoot(root)
+		if err != nil {
+			return "", err
+		}
+		roots[i] = resolved
+	}
+
+	candidates := []string{filename}
+	if !filepath.IsAbs(filename) {
+		candidates = candidates[:0]
+		for _, root := range roots {
+			candidates = append(candidates, filepath.Join(root, filename))
+		}
+	}
+
+	var lastErr error
+	for _, candidate := range candidates {
+		resolved, err := filepath.EvalSymlinks(candidate)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resolved, err = filepath.Abs(resolved)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if !l.hasAllowedExtension(resolved) {
+			return "", fmt.Errorf("%w: %q resolves to %q, which does not have one of the extensions %v",
+				ErrPathNotAllowed, filename, resolved, l.options.allowedExtensions)
+		}
+		for _, root := range roots {
+			if isWithin(root, resolved) {
+				return resolved, nil
+			}
+		}
+	}
+	if lastErr != nil && errors.Is(lastErr, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %q not found in allowed roots %v", ErrPathNotAllowed, filename, l.options.allowedRoots)
+	}
+	return "", fmt.Errorf("%w: %q is outside the allowed roots %v", ErrPathNotAllowed, filename, l.options.allowedRoots)
+}
+
+// hasAllowedExtension reports whether path has one of the allowed extensions, or no extensions are configured.
+func (l *Loader) hasAllowedExtension(path string) bool {
+	return len(l.options.allowedExtensions) == 0 || slices.Contains(l.options.allowedExtensions, filepath.Ext(path))
+}
+
+// resolveRoot converts root into an absolute path with all symlinks resolved,
+// so that it can be compared with resolved script paths.
+func resolveRoot(root string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("invalid script root %q: %w", root, err)
+	}
+	resolved, err = filepath.Abs(resolved)
+	if err != nil {
+		return "", fmt.Errorf("invalid script root %q: %w", root, err)
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", fmt.Errorf("invalid script root %q: %w", root, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("invalid script root %q: not a directory", root)
+	}
+	return resolved, nil
+}
+
+// isWithin reports whether path lies inside the directory root. Both must be absolute and clean.
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
@@ -0,0 +1,126 @@
+package script
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"plugin"
+	"reflect"
+	"strings"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/ma6174/duckgo/script/pluginapi"
+	"github.com/ma6174/duckgo/udf"
+)
+
+// PluginAPIVersion is the version of the contract between AddPluginUDF and Go plugins.
+// Every plugin must declare it in a package-level variable named DuckGoPluginAPIVersion,
+// assigned from the pluginapi package, so that Go checks that the plugin was built against this release:
+//
+//	import "github.com/ma6174/duckgo/script/pluginapi"
+//
+//	var DuckGoPluginAPIVersion = pluginapi.Current
+//
+// The value is increased whenever plugins built for earlier releases can no longer be loaded.
+const PluginAPIVersion = pluginapi.Current
+
+// pluginVersionSymbol is the variable a plugin declares its PluginAPIVersion in.
+const pluginVersionSymbol = "DuckGoPluginAPIVersion"
+
+// ErrIncompatiblePlugin is wrapped by the errors returned for plugins that cannot be used by this program,
+// because they were built with another Go version, other versions of shared packages, or for another PluginAPIVersion.
+var ErrIncompatiblePlugin = errors.New("incompatible plugin")
+
+// AddPluginUDF opens a Go plugin built with -buildmode=plugin and registers the named exported functions as UDFs in DuckDB.
+// Unlike scripts, plugin functions run as native code. See AddPluginUDF of Loader for details.
+func AddPluginUDF(db *sql.DB, path string, funcNames ...string) error {
+	return defaultLoader.AddPluginUDF(db, path, funcNames...)
+}
+
+// AddPluginUDF opens a Go plugin built with -buildmode=plugin and registers the named exported functions as UDFs in DuckDB,
+// applying the file restrictions and udf.Option values of the Loader. Function names may use the "alias=name" form.
+//
+// The plugin must declare DuckGoPluginAPIVersion (see PluginAPIVersion) and be built with the same Go version,
+// the same build flags such as -race, and the same versions of all packages it shares with the program. Plugins are not recorded in the catalog,
+// and a plugin cannot be unloaded or reloaded once opened.
+func (l *Loader) AddPluginUDF(db *sql.DB, path string, funcNames ...string) error {
+	path, err := l.resolvePath(path)
+	if err != nil {
+		return err
+	}
+	p, err := openPlugin(path)
+	if err != nil {
+		return &LoadError{File: path, Err: err}
+	}
+
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for _, spec := range funcNames {
+		sqlName, funcName := parseFuncSpec(spec)
+		fn, err := lookupPluginFunc(p, funcName)
+		if err != nil {
+			return &LoadError{File: path, Func: funcName, Err: err}
+		}
+		opts := append([]udf.Option{udf.WithName(sqlName)}, l.options.udfOptions...)
+		sf, err := udf.BuildScalarUDF(fn, opts...)
+		if err != nil {
+			return &LoadError{File: path, Func: funcName, Err: err}
+		}
+		log.Println("AddPluginUDF", path, spec)
+		if err := duckdb.RegisterScalarUDF(conn, sqlName, sf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openPlugin opens the plugin at path and checks that it declares a supported PluginAPIVersion.
+func openPlugin(path string) (*plugin.Plugin, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		const mismatch = "different version of package "
+		if _, pkg, ok := strings.Cut(err.Error(), mismatch); ok {
+			return nil, fmt.Errorf("%w: plugin was built against another version of package %s than this program; "+
+				"rebuild it with the Go version, build flags and module versions of this program, "+
+				"which supports plugin API version %d", ErrIncompatiblePlugin, pkg, PluginAPIVersion)
+		}
+		return nil, err
+	}
+	sym, err := p.Lookup(pluginVersionSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("%w: plugin does not declare %s, add \"var %s = pluginapi.Current\"",
+			ErrIncompatiblePlugin, pluginVersionSymbol, pluginVersionSymbol)
+	}
+	version, ok := sym.(*pluginapi.Version)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s must be assigned from pluginapi.Current, but is %T",
+			ErrIncompatiblePlugin, pluginVersionSymbol, sym)
+	}
+	if *version != PluginAPIVersion {
+		return nil, fmt.Errorf("%w: plugin was built for plugin API version %d, but this program supports version %d",
+			ErrIncompatiblePlugin, *version, PluginAPIVersion)
+	}
+	return p, nil
+}
+
+// lookupPluginFunc returns the exported function name of the plugin.
+// Function variables are accepted as well; the plugin package returns them as pointers.
+func lookupPluginFunc(p *plugin.Plugin, name string) (any, error) {
+	sym, err := p.Lookup(name)
+	if err != nil {
+		return nil, ErrFuncNotFound
+	}
+	v := reflect.ValueOf(sym)
+	if v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Func {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("symbol %s is not a function, but %T", name, sym)
+	}
+	return v.Interface(), nil
+}
@@ -0,0 +1,130 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var pluginCount atomic.Int64
+
+// buildPlugin compiles src as a Go plugin and returns the path of the shared object. The plugin is built
+// in a module requiring the duckgo module at moduleDir, with the module versions and flags of the tests.
+func buildPlugin(t *testing.T, moduleDir, src string) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("building plugins is slow")
+	}
+	dir := t.TempDir()
+	// Go opens a plugin path only once, so each plugin gets its own module path
+	goMod := fmt.Sprintf("module duckgoplugin%d\n\ngo 1.25.0\n\nrequire github.com/ma6174/duckgo v0.0.0\n\n"+
+		"replace github.com/ma6174/duckgo => %s\n", pluginCount.Add(1), moduleDir)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0o644))
+	goSum, err := os.ReadFile(filepath.Join(moduleDir, "go.sum"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.sum"), goSum, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "plugin.go"), []byte(src), 0o644))
+
+	out := filepath.Join(dir, "udfs.so")
+	args := []string{"build", "-mod=mod", "-buildmode=plugin", "-o", out}
+	if raceEnabled {
+		args = append(args, "-race")
+	}
+	cmd := exec.Command("go", append(args, ".")...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=", "GOPROXY=off")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("plugins are not supported here: %v\n%s", err, output)
+	}
+	return out
+}
+
+// repoDir returns the root directory of the duckgo module.
+func repoDir(t *testing.T) string {
+	t.Helper()
+	dir, err := filepath.Abs("..")
+	require.NoError(t, err)
+	return dir
+}
+
+func TestAddPluginUDF(t *testing.T) {
+	path := buildPlugin(t, repoDir(t), `package main
+
+import (
+	"strings"
+
+	"github.com/ma6174/duckgo/script/pluginapi"
+)
+
+var DuckGoPluginAPIVersion = pluginapi.Current
+
+func Reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+var Upper = strings.ToUpper
+
+var NotAFunc = 42
+`)
+	db := newTestDB(t)
+	require.NoError(t, AddPluginUDF(db, path, "Reverse", "upper=Upper"))
+
+	var result string
+	require.NoError(t, db.QueryRow("select Reverse('duck') || ' ' || upper('go')").Scan(&result))
+	require.Equal(t, "kcud GO", result)
+
+	err := AddPluginUDF(db, path, "Missing")
+	require.ErrorIs(t, err, ErrFuncNotFound)
+	err = AddPluginUDF(db, path, "NotAFunc")
+	require.ErrorContains(t, err, "symbol NotAFunc is not a function, but *int")
+
+	err = NewLoader(WithAllowedExtensions(".go")).AddPluginUDF(db, path, "Reverse")
+	require.ErrorIs(t, err, ErrPathNotAllowed)
+}
+
+func TestAddPluginUDFIncompatible(t *testing.T) {
+	db := newTestDB(t)
+	declared := "package main\n\nimport \"github.com/ma6174/duckgo/script/pluginapi\"\n\n" +
+		"var DuckGoPluginAPIVersion = pluginapi.Current\n\nfunc F() int { return 1 }\n"
+
+	// A copy of the module with another release of the pluginapi package
+	otherRelease := t.TempDir()
+	for _, name := range []string{"go.mod", "go.sum"} {
+		content, err := os.ReadFile(filepath.Join(repoDir(t), name))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(otherRelease, name), content, 0o644))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(otherRelease, "script", "pluginapi"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(otherRelease, "script", "pluginapi", "pluginapi.go"),
+		[]byte("package pluginapi\n\ntype Version int\n\nconst Current Version = 99\n"), 0o644))
+
+	tests := []struct {
+		name, moduleDir, src, message string
+	}{
+		{"missing version", repoDir(t), "package main\n\nfunc F() int { return 1 }\n",
+			"plugin does not declare DuckGoPluginAPIVersion"},
+		{"copied version", repoDir(t), "package main\n\nvar DuckGoPluginAPIVersion = 1\n\nfunc F() int { return 1 }\n",
+			"DuckGoPluginAPIVersion must be assigned from pluginapi.Current, but is *int"},
+		{"other release", otherRelease, declared,
+			"plugin was built against another version of package github.com/ma6174/duckgo/script/pluginapi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := buildPlugin(t, tt.moduleDir, tt.src)
+			err := AddPluginUDF(db, path, "F")
+			require.ErrorIs(t, err, ErrIncompatiblePlugin)
+			require.ErrorContains(t, err, tt.message)
+			var loadErr *LoadError
+			require.ErrorAs(t, err, &loadErr)
+		})
+	}
+}
@@ -0,0 +1,17 @@
+// Package pluginapi declares the version of the contract between script.AddPluginUDF and Go plugins.
+// Plugins import it and assign its Current version to a package-level variable named DuckGoPluginAPIVersion:
+//
+//	import "github.com/ma6174/duckgo/script/pluginapi"
+//
+//	var DuckGoPluginAPIVersion = pluginapi.Current
+//
+// Go checks when opening a plugin that the packages it shares with the program are the same, so a plugin
+// built against another release of this package cannot be opened. The package has no dependencies,
+// so importing it keeps plugins small.
+package pluginapi
+
+// Version is the type of the DuckGoPluginAPIVersion variable of plugins.
+type Version int
+
+// Current is increased whenever plugins built for earlier releases can no longer be loaded.
+const Current Version = 1
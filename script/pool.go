@@ -0,0 +1,30 @@
+package script
+
+import (
+	"reflect"
+)
+
+// getFunc returns the script function name. For an interpreter pool, the returned function borrows an idle
+// instance for every call and calls the function of that instance.
+func (cs *compiledScript) getFunc(name string) (any, bool) {
+	if cs.free == nil {
+		return cs.interps[0].GetFunc(name)
+	}
+	fns := make([]reflect.Value, len(cs.interps))
+	for i, interp := range cs.interps {
+		fn, ok := interp.GetFunc(name)
+		if !ok {
+			return nil, false
+		}
+		fns[i] = reflect.ValueOf(fn)
+	}
+	variadic := fns[0].Type().IsVariadic()
+	return reflect.MakeFunc(fns[0].Type(), func(args []reflect.Value) []reflect.Value {
+		i := <-cs.free
+		defer func() { cs.free <- i }()
+		if variadic {
+			return fns[i].CallSlice(args)
+		}
+		return fns[i].Call(args)
+	}).Interface(), true
+}
@@ -0,0 +1,91 @@
+package script
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpreterPool(t *testing.T) {
+	src := `
+package main
+
+var calls int
+var seen = map[int]int{}
+
+func counted(a int) int {
+	calls++
+	return calls
+}
+
+func remember(x int) int {
+	seen[x%97]++ // 97 is coprime to the pool size, so instances called in turn see every residue
+	return len(seen)
+}
+
+func fail(x int) int {
+	return 10 / x
+}
+`
+	t.Run("instances have their own globals", func(t *testing.T) {
+		db := newTestDB(t)
+		require.NoError(t, NewLoader(WithInterpreterPool(2)).AddIXGoUDFFromSource(db, src, "counted"))
+		// Idle instances are handed out in turn
+		var results []int
+		for range 4 {
+			var result int
+			require.NoError(t, db.QueryRow("select counted(0)").Scan(&result))
+			results = append(results, result)
+		}
+		require.Equal(t, []int{1, 1, 2, 2}, results)
+	})
+
+	t.Run("concurrent calls do not race", func(t *testing.T) {
+		db := newTestDB(t)
+		require.NoError(t, NewLoader(WithInterpreterPool(4)).AddIXGoUDFFromSource(db, src, "remember"))
+		_, err := db.Exec("SET threads = 4; CREATE TABLE nums AS SELECT i::INTEGER AS x FROM range(130000) t(i)")
+		require.NoError(t, err)
+		var maxSeen int
+		require.NoError(t, db.QueryRow("select max(remember(x)) from nums").Scan(&maxSeen))
+		require.Equal(t, 97, maxSeen)
+	})
+
+	t.Run("errors and budgets", func(t *testing.T) {
+		db := newTestDB(t)
+		require.NoError(t, NewLoader(WithInterpreterPool(2), WithMaxSteps(1000)).AddIXGoUDFFromSource(db, src, "fail"))
+		for range 3 { // The instance is returned to the pool after a panic
+			_, err := db.Exec("select fail(0)")
+			require.ErrorContains(t, err, "script function fail failed")
+		}
+	})
+
+	t.Run("restored from the catalog", func(t *testing.T) {
+		dbPath := filepath.Join(t.TempDir(), "pool.duckdb")
+		db, err := sql.Open("duckdb", dbPath)
+		require.NoError(t, err)
+		require.NoError(t, NewLoader(WithCatalog(), WithInterpreterPool(2)).AddIXGoUDFFromSource(db, src, "counted"))
+		require.NoError(t, db.Close())
+
+		db, err = sql.Open("duckdb", dbPath)
+		require.NoError(t, err)
+		defer db.Close()
+		require.NoError(t, RestoreFromCatalog(db))
+		var results []int
+		for range 4 {
+			var result int
+			require.NoError(t, db.QueryRow("select counted(0)").Scan(&result))
+			results = append(results, result)
+		}
+		require.Equal(t, []int{1, 1, 2, 2}, results)
+	})
+
+	t.Run("cached separately", func(t *testing.T) {
+		cache := NewCache("")
+		require.NoError(t, NewLoader(WithCache(cache)).AddIXGoUDFFromSource(newTestDB(t), src, "counted"))
+		require.NoError(t, NewLoader(WithCache(cache), WithInterpreterPool(2)).AddIXGoUDFFromSource(newTestDB(t), src, "counted"))
+		require.NoError(t, NewLoader(WithCache(cache), WithInterpreterPool(2)).AddIXGoUDFFromSource(newTestDB(t), src, "counted"))
+		require.Equal(t, CacheStats{Entries: 2, Hits: 1, Misses: 2}, cache.Stats())
+	})
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package script
+
+// raceEnabled reports whether the tests are built with -race, which plugins they load must be built with too.
+const raceEnabled = true
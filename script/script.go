@@ -1,11 +1,13 @@
 package script
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
-	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
 
 	"github.com/duckdb/duckdb-go/v2"
 	"github.com/goplus/ixgo"
@@ -18,15 +20,58 @@ import (
 // It registers a scalar UDF named "add_ixgo_udf" that allows adding more UDFs
 // from .ixgo files directly within SQL queries.
 // The signature of the SQL function is add_ixgo_udf(filename TEXT, funcNames TEXT...).
-func EnableRegisterUDFFromSQL(db *sql.DB) error {
+//
+// The opts configure the Loader used by add_ixgo_udf. On shared servers, use WithAllowedRoots
+// (and optionally WithAllowedExtensions) to keep SQL users from loading arbitrary files.
+func EnableRegisterUDFFromSQL(db *sql.DB, opts ...Option) error {
+	loader := NewLoader(opts...)
 	addUDF := func(filename string, funcNames ...string) int {
-		err := AddIXGoUDFFromFile(db, filename, funcNames...)
+		err := loader.AddIXGoUDFFromFile(db, filename, funcNames...)
 		if err != nil {
 			panic(fmt.Errorf("add_ixgo_udf: failed to load UDF from %q: %w", filename, err))
 		}
 		return 1
 	}
-	sf, err := udf.BuildScalarUDF(addUDF)
+	return registerSQLFunc(db, "add_ixgo_udf", addUDF)
+}
+
+// EnableCreateUDFFromSQL enables defining user-defined functions (UDFs) from script source text within SQL,
+// for clients without access to the file system of the DuckDB host. It registers two scalar UDFs:
+//
+//   - create_ixgo_udf(source TEXT, funcNames TEXT...) interprets source as an XGo script, which also accepts Go code.
+//   - create_ixgo_udf_lang(language TEXT, source TEXT, funcNames TEXT...) interprets source in the given language, "go" or "xgo".
+//
+// Both register the named functions in the database and return 1, e.g.
+//
+//	SELECT create_ixgo_udf('func double(x int) int { return 2 * x }', 'double')
+//
+// A missing package clause is added automatically. The opts configure the Loader used by both functions.
+// Any SQL user can run arbitrary script code through them, so consider setting WithMaxSteps and udf.WithTimeout.
+func EnableCreateUDFFromSQL(db *sql.DB, opts ...Option) error {
+	loader := NewLoader(opts...)
+	createUDF := func(src string, funcNames ...string) int {
+		err := loader.AddIXGoUDFFromSourceLang(db, LanguageXGo, src, funcNames...)
+		if err != nil {
+			panic(fmt.Errorf("create_ixgo_udf: failed to create UDF: %w", err))
+		}
+		return 1
+	}
+	createUDFLang := func(language, src string, funcNames ...string) int {
+		err := loader.AddIXGoUDFFromSourceLang(db, language, src, funcNames...)
+		if err != nil {
+			panic(fmt.Errorf("create_ixgo_udf_lang: failed to create UDF: %w", err))
+		}
+		return 1
+	}
+	if err := registerSQLFunc(db, "create_ixgo_udf", createUDF); err != nil {
+		return err
+	}
+	return registerSQLFunc(db, "create_ixgo_udf_lang", createUDFLang)
+}
+
+// registerSQLFunc builds a scalar UDF from fn and registers it in db under name.
+func registerSQLFunc(db *sql.DB, name string, fn any) error {
+	sf, err := udf.BuildScalarUDF(fn)
 	if err != nil {
 		return err
 	}
@@ -35,56 +80,185 @@ func EnableRegisterUDFFromSQL(db *sql.DB) error {
 		return err
 	}
 	defer conn.Close()
-	return duckdb.RegisterScalarUDF(conn, "add_ixgo_udf", sf)
+	return duckdb.RegisterScalarUDF(conn, name, sf)
+}
+
+// Loader interprets .go or .xgo scripts and registers their functions as UDFs in DuckDB.
+// The package-level AddIXGoUDF functions use a Loader without options.
+//
+// Functions are registered under their name in the script. A function name of the form "alias=name"
+// registers the script function name under the SQL name alias instead.
+type Loader struct {
+	options loaderOption
+}
+
+// defaultLoader is used by the package-level functions.
+var defaultLoader = NewLoader()
+
+// NewLoader creates a Loader configured by opts, e.g. WithMaxSteps or WithUDFOptions.
+func NewLoader(opts ...Option) *Loader {
+	l := &Loader{}
+	for _, opt := range opts {
+		opt(&l.options)
+	}
+	return l
 }
 
 // AddIXGoUDFFromFile loads an .go or .xgo script from a file and registers the specified functions as UDFs in DuckDB.
 func AddIXGoUDFFromFile(db *sql.DB, filename string, funcNames ...string) (err error) {
-	return addIXGoUDF(db, filename, nil, funcNames...)
+	return defaultLoader.AddIXGoUDFFromFile(db, filename, funcNames...)
 }
 
 // AddIXGoUDFFromSource loads an .go or .xgo script from a source string or byte slice and registers the specified functions as UDFs in DuckDB.
 // The filename is used for error reporting.
 func AddIXGoUDFFromSource(db *sql.DB, src any, funcNames ...string) (err error) {
-	return addIXGoUDF(db, "main.xgo", src, funcNames...)
+	return defaultLoader.AddIXGoUDFFromSource(db, src, funcNames...)
 }
 
-// addIXGoUDF is an internal function that handles the logic for loading an .go or .xgo package
-// from either a file or source, interpreting it, and registering the specified functions
-// as scalar UDFs in DuckDB.
-func addIXGoUDF(db *sql.DB, filename string, src any, funcNames ...string) (err error) {
-	conn, err := db.Conn(context.Background())
+// AddIXGoUDFFromFile loads an .go or .xgo script from a file and registers the specified functions as UDFs in DuckDB,
+// applying the options of the Loader.
+func (l *Loader) AddIXGoUDFFromFile(db *sql.DB, filename string, funcNames ...string) (err error) {
+	path, err := l.resolvePath(filename)
 	if err != nil {
 		return err
 	}
-	defer conn.Close()
-	ctx := ixgo.NewContext(0)
-	pkg, err := ctx.LoadFile(filename, src)
+	return l.addIXGoUDF(db, path, nil, funcNames...)
+}
+
+// AddIXGoUDFFromSource loads an .go or .xgo script from a source string or byte slice and registers the specified functions as UDFs in DuckDB,
+// applying the options of the Loader.
+func (l *Loader) AddIXGoUDFFromSource(db *sql.DB, src any, funcNames ...string) (err error) {
+	return l.addIXGoUDF(db, "main.xgo", src, funcNames...)
+}
+
+// AddIXGoUDFFromSourceLang is like AddIXGoUDFFromSource, but interprets the source string in the given language,
+// LanguageGo or LanguageXGo. A missing package clause is added automatically.
+func (l *Loader) AddIXGoUDFFromSourceLang(db *sql.DB, language, src string, funcNames ...string) (err error) {
+	filename, err := sourceFilename(language)
 	if err != nil {
 		return err
 	}
-	interp, err := ctx.NewInterp(pkg)
+	return l.addIXGoUDF(db, filename, withPackageClause(src), funcNames...)
+}
+
+// addIXGoUDF is an internal function that handles the logic for loading an .go or .xgo package
+// from either a file or source, interpreting it, and registering the specified functions
+// as scalar UDFs in DuckDB.
+func (l *Loader) addIXGoUDF(db *sql.DB, filename string, src any, funcNames ...string) (err error) {
+	_, err = l.addSelectedIXGoUDF(db, filename, src, func(*ixgo.Interp) []string { return funcNames })
+	return err
+}
+
+// addSelectedIXGoUDF loads the script like addIXGoUDF, but registers the functions returned by selectFuncs
+// for the interpreted script. It returns the registered function names.
+func (l *Loader) addSelectedIXGoUDF(db *sql.DB, filename string, src any, selectFuncs func(*ixgo.Interp) []string) ([]string, error) {
+	conn, err := db.Conn(context.Background())
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer conn.Close()
+	var content []byte
+	if l.options.catalog {
+		// Read the source once, so that the catalog records exactly what was compiled
+		if content, err = sourceContent(filename, src); err != nil {
+			return nil, newLoadError(filename, "", err)
+		}
+		src = content
 	}
-	err = interp.RunInit()
+	cs, err := l.loadScript(filename, src)
 	if err != nil {
-		return err
+		return nil, newLoadError(filename, "", err)
 	}
-	for _, funcName := range funcNames {
-		fi, ok := interp.GetFunc(funcName)
+	funcNames := selectFuncs(cs.interps[0])
+	for _, spec := range funcNames {
+		sqlName, funcName := parseFuncSpec(spec)
+		fi, ok := cs.getFunc(funcName)
 		if !ok {
-			return errors.New("func not found")
+			return nil, newLoadError(filename, funcName, ErrFuncNotFound)
 		}
-		sf, err := udf.BuildScalarUDF(fi)
+		fi = wrapScriptFunc(fi, funcName, l.options.maxSteps)
+		opts := append([]udf.Option{udf.WithName(sqlName)}, l.options.udfOptions...)
+		sf, err := udf.BuildScalarUDF(fi, opts...)
 		if err != nil {
-			return err
+			return nil, newLoadError(filename, funcName, err)
 		}
-		log.Println("AddIXGoUDF", filename, funcName)
-		err = duckdb.RegisterScalarUDF(conn, funcName, sf)
+		log.Println("AddIXGoUDF", filename, spec)
+		err = duckdb.RegisterScalarUDF(conn, sqlName, sf)
 		if err != nil {
-			return err
+			return nil, err
+		}
+	}
+	if l.options.catalog {
+		if err := l.recordInCatalog(conn, filename, content, funcNames); err != nil {
+			return nil, err
 		}
 	}
-	return nil
+	return funcNames, nil
+}
+
+// loadScript returns the initialized interpreters for the script, from the cache of the Loader if it has one.
+func (l *Loader) loadScript(filename string, src any) (*compiledScript, error) {
+	if l.options.cache == nil {
+		return l.compile(filename, src)
+	}
+	content, err := sourceContent(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	return l.options.cache.load(filename, content, l.options.maxSteps > 0, l.options.poolSize, l.compile)
+}
+
+// compile interprets the script and runs its package initialization, once for every instance of the
+// interpreter pool if the Loader has one.
+func (l *Loader) compile(filename string, src any) (*compiledScript, error) {
+	var mode ixgo.Mode
+	if l.options.poolSize > 0 {
+		mode = ixgo.SupportMultipleInterp
+	}
+	ctx := ixgo.NewContext(mode)
+	if l.options.maxSteps > 0 {
+		enableStepCounting(ctx)
+	}
+	recordPanics(ctx)
+	pkg, err := ctx.LoadFile(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	cs := &compiledScript{}
+	for range max(l.options.poolSize, 1) {
+		interp, err := ctx.NewInterp(pkg)
+		if err != nil {
+			return nil, err
+		}
+		if err := interp.RunInit(); err != nil {
+			return nil, err
+		}
+		cs.interps = append(cs.interps, interp)
+	}
+	if l.options.poolSize > 0 {
+		cs.free = make(chan int, len(cs.interps))
+		for i := range cs.interps {
+			cs.free <- i
+		}
+	}
+	return cs, nil
+}
+
+// sourceContent returns the script content, reading filename if src is nil.
+// It accepts the same source types as the interpreter: string, []byte, *bytes.Buffer and io.Reader.
+func sourceContent(filename string, src any) ([]byte, error) {
+	switch s := src.(type) {
+	case nil:
+		return os.ReadFile(filename)
+	case string:
+		return []byte(s), nil
+	case []byte:
+		return s, nil
+	case *bytes.Buffer:
+		return s.Bytes(), nil
+	case io.Reader:
+		return io.ReadAll(s)
+	default:
+		return nil, fmt.Errorf("invalid script source type %T", src)
+	}
 }
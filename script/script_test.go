@@ -86,6 +86,346 @@ func mymul(a,b int) int {
 	require.Equal(t, 12, result)
 }
 
+func TestEnableRegisterUDFFromSQLAllowedRoots(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	src := []byte(`
+package main
+
+func myneg(a int) int {
+	return -a
+}
+`)
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "sub", "neg.go"), src, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "neg.txt"), src, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "neg.go"), src, 0o644))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "neg.go"), filepath.Join(root, "link.go")))
+	require.NoError(t, os.Symlink(filepath.Join(root, "neg.txt"), filepath.Join(root, "txt.go")))
+
+	db := newTestDB(t)
+	err := EnableRegisterUDFFromSQL(db, WithAllowedRoots(root), WithAllowedExtensions(".go", ".xgo"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		filename      string
+		errorContains string
+	}{
+		{"relative path inside root", "sub/neg.go", ""},
+		{"absolute path inside root", filepath.Join(root, "sub", "neg.go"), ""},
+		{"absolute path outside root", filepath.Join(outside, "neg.go"), "is outside the allowed roots"},
+		{"relative path escaping root", "../" + filepath.Base(outside) + "/neg.go", "is outside the allowed roots"},
+		{"symlink escaping root", "link.go", "is outside the allowed roots"},
+		{"disallowed extension", "neg.txt", "does not have one of the extensions [.go .xgo]"},
+		{"symlink to disallowed extension", "txt.go", "which does not have one of the extensions"},
+		{"missing file", "missing.go", "not found in allowed roots"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := db.Exec("select add_ixgo_udf(?, 'myneg')", tt.filename)
+			if tt.errorContains == "" {
+				require.NoError(t, err)
+				var result int
+				require.NoError(t, db.QueryRow("select myneg(3)").Scan(&result))
+				require.Equal(t, -3, result)
+				return
+			}
+			require.ErrorContains(t, err, "script path not allowed")
+			require.ErrorContains(t, err, tt.errorContains)
+		})
+	}
+
+	t.Run("loader error wraps ErrPathNotAllowed", func(t *testing.T) {
+		err := NewLoader(WithAllowedRoots(root)).AddIXGoUDFFromFile(db, filepath.Join(outside, "neg.go"), "myneg")
+		require.ErrorIs(t, err, ErrPathNotAllowed)
+	})
+
+	t.Run("invalid root", func(t *testing.T) {
+		err := NewLoader(WithAllowedRoots(filepath.Join(root, "nope"))).AddIXGoUDFFromFile(db, "sub/neg.go", "myneg")
+		require.ErrorContains(t, err, "invalid script root")
+	})
+}
+
+func TestEnableCreateUDFFromSQL(t *testing.T) {
+	db := newTestDB(t)
+	err := EnableCreateUDFFromSQL(db, WithMaxSteps(10000))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		create        string
+		args          []any
+		query         string
+		expected      string
+		errorContains string
+	}{
+		{
+			name:     "xgo snippet without package clause",
+			create:   "select create_ixgo_udf(?, 'double_it')",
+			args:     []any{`func double_it(x int) int { return 2 * x }`},
+			query:    "select double_it(21)::VARCHAR",
+			expected: "42",
+		},
+		{
+			name:   "go source with imports",
+			create: "select create_ixgo_udf_lang('go', ?, 'slug', 'shout')",
+			args: []any{`import "strings"
+
+func slug(s string) string { return strings.ReplaceAll(strings.ToLower(s), " ", "-") }
+
+func shout(s string) string { return strings.ToUpper(s) + "!" }`},
+			query:    "select slug('Hello Big World') || ' ' || shout('hi')",
+			expected: "hello-big-world HI!",
+		},
+		{
+			name:   "go source with package clause",
+			create: "select create_ixgo_udf_lang('go', ?, 'triple')",
+			args: []any{`package main
+
+func triple(x int) int { return 3 * x }`},
+			query:    "select triple(5)::VARCHAR",
+			expected: "15",
+		},
+		{
+			name:          "unknown language",
+			create:        "select create_ixgo_udf_lang('rust', 'fn main() {}', 'main')",
+			errorContains: `create_ixgo_udf_lang: failed to create UDF: unsupported script language "rust"`,
+		},
+		{
+			name:          "syntax error",
+			create:        "select create_ixgo_udf_lang('go', 'func broken( {', 'broken')",
+			errorContains: "create_ixgo_udf_lang: failed to create UDF",
+		},
+		{
+			name:          "loader options apply",
+			create:        "select create_ixgo_udf(?, 'forever')",
+			args:          []any{`func forever(x int) int { for { x++ } }`},
+			query:         "select forever(1)::VARCHAR",
+			errorContains: "exceeded its budget of 10000 steps",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := db.Exec(tt.create, tt.args...)
+			if tt.query == "" {
+				require.ErrorContains(t, err, tt.errorContains)
+				return
+			}
+			require.NoError(t, err)
+			var result string
+			err = db.QueryRow(tt.query).Scan(&result)
+			if tt.errorContains != "" {
+				require.ErrorContains(t, err, tt.errorContains)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestLoaderCache(t *testing.T) {
+	src := `
+package main
+
+var calls int
+
+func counted(a int) int {
+	calls++
+	return a + calls
+}
+`
+	t.Run("in memory", func(t *testing.T) {
+		cache := NewCache("")
+		loader := NewLoader(WithCache(cache))
+		db1 := newTestDB(t)
+		db2 := newTestDB(t)
+		require.NoError(t, loader.AddIXGoUDFFromSource(db1, src, "counted"))
+		require.NoError(t, loader.AddIXGoUDFFromSource(db2, src, "counted"))
+		require.Equal(t, CacheStats{Entries: 1, Hits: 1, Misses: 1}, cache.Stats())
+
+		// Both databases share one interpreter, including the package-level counter
+		var result int
+		require.NoError(t, db1.QueryRow("select counted(10)").Scan(&result))
+		require.Equal(t, 11, result)
+		require.NoError(t, db2.QueryRow("select counted(10)").Scan(&result))
+		require.Equal(t, 12, result)
+
+		// Different source, or a different compilation mode, is compiled separately
+		require.NoError(t, loader.AddIXGoUDFFromSource(db1, src+"\n// changed\n", "counted"))
+		require.NoError(t, NewLoader(WithCache(cache), WithMaxSteps(100)).AddIXGoUDFFromSource(db1, src, "counted"))
+		require.Equal(t, CacheStats{Entries: 3, Hits: 1, Misses: 3}, cache.Stats())
+	})
+
+	t.Run("files are keyed by content", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "counted.go")
+		require.NoError(t, os.WriteFile(file, []byte(src), 0o644))
+		cache := NewCache("")
+		loader := NewLoader(WithCache(cache))
+		require.NoError(t, loader.AddIXGoUDFFromFile(newTestDB(t), file, "counted"))
+		require.NoError(t, loader.AddIXGoUDFFromFile(newTestDB(t), file, "counted"))
+		require.NoError(t, os.WriteFile(file, []byte(src+"\nfunc other() int { return 1 }\n"), 0o644))
+		require.NoError(t, loader.AddIXGoUDFFromFile(newTestDB(t), file, "counted", "other"))
+		require.Equal(t, CacheStats{Entries: 2, Hits: 1, Misses: 2}, cache.Stats())
+	})
+
+	t.Run("on disk", func(t *testing.T) {
+		dir := t.TempDir()
+		xgoSrc := `
+func greet(name string) string {
+	return "hello " + name
+}
+`
+		first := NewCache(dir)
+		require.NoError(t, NewLoader(WithCache(first)).AddIXGoUDFFromSource(newTestDB(t), xgoSrc, "greet"))
+		require.Equal(t, CacheStats{Entries: 1, Misses: 1}, first.Stats())
+		files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+
+		// A new cache, as in a new process, reuses the generated Go code
+		second := NewCache(dir)
+		db := newTestDB(t)
+		require.NoError(t, NewLoader(WithCache(second)).AddIXGoUDFFromSource(db, xgoSrc, "greet"))
+		require.Equal(t, CacheStats{Entries: 1, Misses: 1, DiskHits: 1}, second.Stats())
+		var result string
+		require.NoError(t, db.QueryRow("select greet('duck')").Scan(&result))
+		require.Equal(t, "hello duck", result)
+
+		// Other interpreter options compile the script again from the same generated code
+		require.NoError(t, NewLoader(WithCache(second), WithMaxSteps(100), WithInterpreterPool(2)).
+			AddIXGoUDFFromSource(newTestDB(t), xgoSrc, "greet"))
+		require.Equal(t, CacheStats{Entries: 2, Misses: 2, DiskHits: 2}, second.Stats())
+		files, err = filepath.Glob(filepath.Join(dir, "*.go"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+	})
+}
+
+func TestLoadError(t *testing.T) {
+	db := newTestDB(t)
+	loader := NewLoader()
+
+	tests := []struct {
+		name     string
+		language string
+		src      string
+		funcName string
+		expected LoadError
+	}{
+		{"go syntax error", LanguageGo, "package main\n\nfunc broken( {\n}\n", "broken", LoadError{File: "main.go", Line: 3, Col: 14}},
+		{"go type error", LanguageGo, "package main\n\nfunc f() int {\n\treturn \"x\"\n}\n", "f", LoadError{File: "main.go", Line: 4, Col: 9}},
+		// The package clause added to the snippet shifts it down by two lines
+		{"xgo type error", LanguageXGo, "func f() int {\n\treturn undefinedVar\n}\n", "f", LoadError{File: "main.xgo", Line: 4, Col: 9}},
+		{"func not found", LanguageGo, "func f() int { return 1 }", "g", LoadError{File: "main.go", Func: "g"}},
+		{"unsupported signature", LanguageGo, "func f() chan int { return nil }", "f", LoadError{File: "main.go", Func: "f"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := loader.AddIXGoUDFFromSourceLang(db, tt.language, tt.src, tt.funcName)
+			var loadErr *LoadError
+			require.ErrorAs(t, err, &loadErr)
+			require.Equal(t, tt.expected.File, loadErr.File)
+			require.Equal(t, tt.expected.Line, loadErr.Line)
+			require.Equal(t, tt.expected.Col, loadErr.Col)
+			require.Equal(t, tt.expected.Func, loadErr.Func)
+		})
+	}
+
+	err := loader.AddIXGoUDFFromSourceLang(db, LanguageGo, "func f() int { return 1 }", "g")
+	require.ErrorIs(t, err, ErrFuncNotFound)
+	require.EqualError(t, err, "main.go: func g: function not found")
+
+	err = loader.AddIXGoUDFFromSourceLang(db, LanguageGo, "func f() chan int { return nil }", "f")
+	var buildErr *udf.BuildError
+	require.ErrorAs(t, err, &buildErr)
+	require.Equal(t, "result", buildErr.Param)
+}
+
+func TestRuntimeError(t *testing.T) {
+	db := newTestDB(t)
+	err := AddIXGoUDFFromSource(db, `package main
+
+func checked(n int) int {
+	if n < 0 {
+		panic("negative input")
+	}
+	return 100 / n
+}
+
+func recovering(n int) (x int) {
+	defer func() {
+		if r := recover(); r != nil {
+			x = -1
+		}
+	}()
+	return checked(n)
+}
+`, "checked", "recovering")
+	require.NoError(t, err)
+
+	var result int
+	err = db.QueryRow("select checked(-1)").Scan(&result)
+	require.ErrorContains(t, err, "panic in UDF checked (func type func(int) int): script function checked failed at main.xgo:5:8: negative input")
+
+	err = db.QueryRow("select checked(0)").Scan(&result)
+	require.ErrorContains(t, err, "script function checked failed: runtime error: integer divide by zero")
+
+	// Recovering inside the script still sees the original panic value
+	require.NoError(t, db.QueryRow("select recovering(-1)").Scan(&result))
+	require.Equal(t, -1, result)
+}
+
+func TestRestoreFromCatalog(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "catalog.duckdb")
+	scriptPath := filepath.Join(dir, "math.go")
+	err := os.WriteFile(scriptPath, []byte(`package main
+
+func add(a, b int) int { return a + b }
+
+func sub(a, b int) int { return a - b }
+`), 0o644)
+	require.NoError(t, err)
+
+	db, err := sql.Open("duckdb", dbPath)
+	require.NoError(t, err)
+	// Nothing to restore in a database without a catalog
+	require.NoError(t, RestoreFromCatalog(db))
+	loader := NewLoader(WithCatalog(), WithMaxSteps(1000))
+	require.NoError(t, loader.AddIXGoUDFFromFile(db, scriptPath, "add"))
+	// Loading the same script again merges the functions into its catalog entry
+	require.NoError(t, loader.AddIXGoUDFFromFile(db, scriptPath, "minus=sub"))
+	require.NoError(t, loader.AddIXGoUDFFromSourceLang(db, LanguageXGo, `func shout(s string) string { return s + "!" }`, "shout"))
+	require.NoError(t, EnableCreateUDFFromSQL(db, WithCatalog()))
+	_, err = db.Exec(`select create_ixgo_udf_lang('go', 'func twice(x int) int { return 2 * x }', 'twice')`)
+	require.NoError(t, err)
+
+	var entries int
+	require.NoError(t, db.QueryRow("select count(*) from "+CatalogTable).Scan(&entries))
+	require.Equal(t, 3, entries)
+	require.NoError(t, db.Close())
+
+	// The script file is not needed to restore the functions
+	require.NoError(t, os.Remove(scriptPath))
+	db, err = sql.Open("duckdb", dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, RestoreFromCatalog(db))
+
+	var result string
+	err = db.QueryRow("select add(1, 2) || ' ' || minus(5, 3) || ' ' || shout('hi') || ' ' || twice(4)").Scan(&result)
+	require.NoError(t, err)
+	require.Equal(t, "3 2 hi! 8", result)
+
+	// The stored step budget is restored as well
+	var options string
+	require.NoError(t, db.QueryRow("select options from "+CatalogTable+" where filename = 'math.go'").Scan(&options))
+	require.JSONEq(t, `{"max_steps":1000}`, options)
+}
+
 func TestAddUDF(t *testing.T) {
 	c, err := duckdb.NewConnector("", nil)
 	require.NoError(t, err)
@@ -114,6 +454,63 @@ func TestEnableRegisterUDFFromSQLError(t *testing.T) {
 	require.Contains(t, err.Error(), "add_ixgo_udf: failed to load UDF")
 }
 
+func TestLoaderMaxSteps(t *testing.T) {
+	src := `
+package main
+
+func spin(n int) int {
+	x := 0
+	for i := 0; i < n; i++ {
+		x += i
+	}
+	return x
+}
+
+func stubborn(n int) (x int) {
+	defer func() { recover() }()
+	for {
+		x += n
+	}
+}
+`
+	t.Run("within budget", func(t *testing.T) {
+		db := newTestDB(t)
+		err := NewLoader(WithMaxSteps(10000)).AddIXGoUDFFromSource(db, src, "spin")
+		require.NoError(t, err)
+		var result int
+		err = db.QueryRow("select spin(10)").Scan(&result)
+		require.NoError(t, err)
+		require.Equal(t, 45, result)
+	})
+
+	t.Run("budget exceeded", func(t *testing.T) {
+		db := newTestDB(t)
+		err := NewLoader(WithMaxSteps(1000)).AddIXGoUDFFromSource(db, src, "spin")
+		require.NoError(t, err)
+		_, err = db.Exec("select spin(1000000000)")
+		require.ErrorContains(t, err, "UDF execution limit exceeded: script function spin exceeded its budget of 1000 steps")
+	})
+
+	t.Run("budget cannot be recovered by the script", func(t *testing.T) {
+		db := newTestDB(t)
+		err := NewLoader(WithMaxSteps(1000)).AddIXGoUDFFromSource(db, src, "stubborn")
+		require.NoError(t, err)
+		_, err = db.Exec("select stubborn(1)")
+		require.ErrorContains(t, err, "exceeded its budget of 1000 steps")
+	})
+
+	t.Run("budget exceeded returns NULL", func(t *testing.T) {
+		db := newTestDB(t)
+		loader := NewLoader(WithMaxSteps(1000), WithUDFOptions(udf.WithLimitPolicy(udf.LimitReturnNull)))
+		err := loader.AddIXGoUDFFromSource(db, src, "spin")
+		require.NoError(t, err)
+		var result sql.NullInt64
+		err = db.QueryRow("select spin(1000000000)").Scan(&result)
+		require.NoError(t, err)
+		require.False(t, result.Valid)
+	})
+}
+
 func BenchmarkNativeUDF(b *testing.B) {
 	db := newTestDB(b)
 	add := func(a, b int) int {
@@ -0,0 +1,42 @@
+package script
+
+import (
+	"fmt"
+
+	"github.com/goplus/ixgo"
+	"github.com/ma6174/duckgo/udf"
+	"github.com/timandy/routine"
+)
+
+// stepBudget tracks the interpreter steps taken by one call of a script function.
+type stepBudget struct {
+	funcName string
+	steps    int64
+	maxSteps int64
+}
+
+// currentBudget holds the budget of the script function call running on the current goroutine.
+// The interpreter executes a function on the goroutine that calls it, so the budget follows the call.
+var currentBudget = routine.NewThreadLocal[*stepBudget]()
+
+// enableStepCounting makes the interpreter report every step of code loaded into ctx to the budget
+// of the running call. It must be called before any package is loaded into ctx.
+func enableStepCounting(ctx *ixgo.Context) {
+	ctx.SetDebug(countStep)
+	// The SSA builder copies the builder mode on creation, so it has to be recreated to pick up debug mode.
+	ctx.Builder = ixgo.NewSSABuilder(ctx)
+}
+
+// countStep is the interpreter debug hook charging one step to the budget of the running call.
+// Once the budget is exhausted every further step panics again, so scripts cannot recover from it.
+func countStep(*ixgo.DebugInfo) {
+	b := currentBudget.Get()
+	if b == nil {
+		return // Not called from a budgeted function, e.g. during package initialization
+	}
+	b.steps++
+	if b.steps > b.maxSteps {
+		panic(fmt.Errorf("%w: script function %s exceeded its budget of %d steps",
+			udf.ErrLimitExceeded, b.funcName, b.maxSteps))
+	}
+}
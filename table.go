@@ -0,0 +1,97 @@
+package duckgo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/ma6174/duckgo/internal/convert"
+)
+
+// column is a table column backed by a field of a Go struct.
+type column struct {
+	name  string
+	index int // Index of the struct field
+	info  duckdb.TypeInfo
+	zero  any // Non-NULL value of the column type
+}
+
+// structColumns returns the columns of a table holding values of struct type t, one per field named by
+// columnFields, with the SQL types UDFs map the field types to.
+func structColumns(t reflect.Type) ([]column, error) {
+	columns, err := columnFields(t)
+	if err != nil {
+		return nil, err
+	}
+	for i, c := range columns {
+		field := t.Field(c.index)
+		info, err := convert.TypeInfoOf(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s of %s: %w", field.Name, t, err)
+		}
+		elem := field.Type
+		if elem.Kind() == reflect.Pointer {
+			elem = elem.Elem()
+		}
+		zero, err := convert.ToDuckDB(reflect.Zero(elem).Interface())
+		if err != nil {
+			return nil, fmt.Errorf("field %s of %s: %w", field.Name, t, err)
+		}
+		columns[i].info, columns[i].zero = info, zero
+	}
+	return columns, nil
+}
+
+// columnFields returns the names of the columns of struct type t, without their types: one per exported field,
+// named by the duckdb tag of the field, or the field name. Fields tagged duckdb:"-" are skipped. Like STRUCT
+// values of UDFs, embedded structs are single columns.
+func columnFields(t reflect.Type) ([]column, error) {
+	if t.Kind() != reflect.Struct || t == reflect.TypeOf(time.Time{}) {
+		return nil, fmt.Errorf("rows must be structs, but got %s", t)
+	}
+	var columns []column
+	seen := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("duckdb")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		key := strings.ToLower(name) // Column names are case-insensitive
+		if seen[key] {
+			return nil, fmt.Errorf("struct %s has several columns named %q", t, name)
+		}
+		seen[key] = true
+		columns = append(columns, column{name: name, index: i})
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("struct %s has no exported fields", t)
+	}
+	return columns, nil
+}
+
+// columnInfos returns the DuckDB descriptions of columns.
+func columnInfos(columns []column) []duckdb.ColumnInfo {
+	infos := make([]duckdb.ColumnInfo, len(columns))
+	for i, c := range columns {
+		infos[i] = duckdb.ColumnInfo{Name: c.name, T: c.info}
+	}
+	return infos
+}
+
+// columnValue returns the value of column c of the struct row, converted for DuckDB. Nil pointers are NULL.
+func columnValue(row reflect.Value, c column) (any, error) {
+	v := row.Field(c.index)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	return convert.ToDuckDB(v.Interface())
+}
@@ -0,0 +1,103 @@
+// Package types exposes the mapping between Go types and DuckDB types used throughout duckgo:
+// by UDFs for their parameters and results, and by the duckgo package for tables and query results.
+// Tools such as schema or documentation generators can use it to predict the SQL types of Go values
+// and to convert values the way duckgo does.
+//
+// The mapping is:
+//
+//	int, int8, int16, int32       INTEGER
+//	uint, uint8, uint16, uint32   UINTEGER
+//	int64, uint64                 BIGINT, UBIGINT
+//	float32, float64              FLOAT, DOUBLE
+//	string, bool, []byte          VARCHAR, BOOLEAN, BLOB
+//	time.Time                     TIMESTAMP
+//	struct                        STRUCT of its exported fields
+//	map[K]V                       MAP(K, V)
+//
+// Pointers are dereferenced once; nil pointers are NULL.
+package types
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/ma6174/duckgo/internal/convert"
+)
+
+// TypeInfoOf returns the DuckDB type of values of Go type t.
+func TypeInfoOf(t reflect.Type) (duckdb.TypeInfo, error) {
+	return convert.TypeInfoOf(t)
+}
+
+// SQLTypeString returns the SQL spelling of the DuckDB type of values of Go type t,
+// such as BIGINT or STRUCT("ID" INTEGER, "Name" VARCHAR), usable in DDL statements and casts.
+func SQLTypeString(t reflect.Type) (string, error) {
+	return convert.SQLType(t)
+}
+
+// FromDuckDB converts a value read from DuckDB, such as a UDF argument or a column of a query result,
+// into a value of Go type t. STRUCT values convert to structs with matching field names, MAP values
+// to maps and LIST values to slices. NULL converts to nil pointers, maps and slices, and to zero structs.
+// Lossy conversions, such as numbers to strings, are errors.
+func FromDuckDB(value driver.Value, t reflect.Type) (reflect.Value, error) {
+	return convert.FromDuckDB(value, t)
+}
+
+// ToDuckDB converts a Go value, such as a UDF result, into a value DuckDB accepts for its type.
+func ToDuckDB(value any) (any, error) {
+	return convert.ToDuckDB(value)
+}
+
+// Signature describes the SQL signature of a Go function used as a UDF.
+type Signature struct {
+	Params   []string // SQL types of the fixed parameters
+	Variadic string   // SQL type of the variadic arguments, empty unless the function is variadic
+	Result   string   // SQL type of the result
+}
+
+// SignatureOf returns the SQL signature of a UDF implemented by a Go function of type t,
+// which must return a single value and have parameters and a result of supported types.
+func SignatureOf(t reflect.Type) (Signature, error) {
+	if t.Kind() != reflect.Func {
+		return Signature{}, fmt.Errorf("%s is not a function", t)
+	}
+	if t.NumOut() != 1 {
+		return Signature{}, fmt.Errorf("function %s must return exactly one value, but returns %d", t, t.NumOut())
+	}
+	var sig Signature
+	numFixed := t.NumIn()
+	if t.IsVariadic() {
+		numFixed--
+		variadic, err := SQLTypeString(t.In(numFixed).Elem())
+		if err != nil {
+			return Signature{}, fmt.Errorf("variadic arguments of %s: %w", t, err)
+		}
+		sig.Variadic = variadic
+	}
+	for i := range numFixed {
+		param, err := SQLTypeString(t.In(i))
+		if err != nil {
+			return Signature{}, fmt.Errorf("argument %d of %s: %w", i, t, err)
+		}
+		sig.Params = append(sig.Params, param)
+	}
+	result, err := SQLTypeString(t.Out(0))
+	if err != nil {
+		return Signature{}, fmt.Errorf("result of %s: %w", t, err)
+	}
+	sig.Result = result
+	return sig, nil
+}
+
+// SQL returns the signature of the function name as SQL text, such as
+// format_money(DOUBLE, VARCHAR, INTEGER) -> VARCHAR or concat_all(VARCHAR, VARCHAR...) -> VARCHAR.
+func (s Signature) SQL(name string) string {
+	params := s.Params
+	if s.Variadic != "" {
+		params = append(params[:len(params):len(params)], s.Variadic+"...")
+	}
+	return name + "(" + strings.Join(params, ", ") + ") -> " + s.Result
+}
@@ -0,0 +1,87 @@
+package types
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+type order struct {
+	ID    int32
+	Items map[string]*float64
+	At    *time.Time
+	note  string
+}
+
+func TestSQLTypeString(t *testing.T) {
+	got, err := SQLTypeString(reflect.TypeFor[order]())
+	want := `STRUCT("ID" INTEGER, "Items" MAP(VARCHAR, DOUBLE), "At" TIMESTAMP)`
+	if err != nil || got != want {
+		t.Errorf("SQLTypeString = %q, %v, want %q", got, err, want)
+	}
+	info, err := TypeInfoOf(reflect.TypeFor[order]())
+	if err != nil || info.InternalType() != duckdb.TYPE_STRUCT {
+		t.Errorf("TypeInfoOf = %v, %v, want a STRUCT", info, err)
+	}
+	if _, err := SQLTypeString(reflect.TypeFor[chan int]()); err == nil {
+		t.Error("expected an error for a channel")
+	}
+}
+
+func TestConversions(t *testing.T) {
+	v, err := FromDuckDB(map[string]any{"ID": int32(7), "Items": nil, "At": nil}, reflect.TypeFor[order]())
+	if err != nil {
+		t.Fatalf("FromDuckDB failed: %v", err)
+	}
+	if o := v.Interface().(order); o.ID != 7 || o.Items != nil || o.At != nil {
+		t.Errorf("FromDuckDB = %+v", o)
+	}
+	out, err := ToDuckDB(map[string]int32{"a": 1})
+	if err != nil {
+		t.Fatalf("ToDuckDB failed: %v", err)
+	}
+	if m, ok := out.(duckdb.OrderedMap); !ok || m.Len() != 1 {
+		t.Errorf("ToDuckDB = %#v, want an OrderedMap with one entry", out)
+	}
+}
+
+func TestSignatureOf(t *testing.T) {
+	tests := []struct {
+		fn   any
+		want string
+	}{
+		{func(amount float64, currency string, decimals int) string { return "" }, "f(DOUBLE, VARCHAR, INTEGER) -> VARCHAR"},
+		{func(sep string, parts ...string) string { return "" }, "f(VARCHAR, VARCHAR...) -> VARCHAR"},
+		{func() time.Time { return time.Time{} }, "f() -> TIMESTAMP"},
+		{func(o *order) []byte { return nil }, `f(STRUCT("ID" INTEGER, "Items" MAP(VARCHAR, DOUBLE), "At" TIMESTAMP)) -> BLOB`},
+	}
+	for _, tt := range tests {
+		sig, err := SignatureOf(reflect.TypeOf(tt.fn))
+		if err != nil {
+			t.Errorf("SignatureOf(%T) failed: %v", tt.fn, err)
+			continue
+		}
+		if got := sig.SQL("f"); got != tt.want {
+			t.Errorf("SignatureOf(%T).SQL = %q, want %q", tt.fn, got, tt.want)
+		}
+	}
+
+	errTests := []struct {
+		fn     any
+		errMsg string
+	}{
+		{42, "is not a function"},
+		{func() {}, "must return exactly one value"},
+		{func(c chan int) int { return 0 }, "argument 0"},
+		{func(s ...any) int { return 0 }, "variadic arguments"},
+		{func() []int { return nil }, "result"},
+	}
+	for _, tt := range errTests {
+		if _, err := SignatureOf(reflect.TypeOf(tt.fn)); err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+			t.Errorf("SignatureOf(%T) error = %v, want it to contain %q", tt.fn, err, tt.errMsg)
+		}
+	}
+}
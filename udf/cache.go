@@ -0,0 +1,207 @@
+package udf
+
+import (
+	"container/list"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrVolatileCache is the reason of the BuildError returned when WithCache is combined with WithVolatile(true).
+// Volatile functions may return different results for the same arguments, so their results must not be reused.
+var ErrVolatileCache = errors.New("results of volatile functions cannot be cached")
+
+// Cache stores results of a UDF by its arguments. Implementations must be safe for concurrent use,
+// because DuckDB executes a UDF on several worker threads at once.
+//
+// Keys are built from the argument values of a row and are only meaningful for one UDF,
+// so every UDF needs a Cache of its own.
+type Cache interface {
+	// Get returns the result stored for key, if any.
+	Get(key string) (value any, ok bool)
+	// Add stores the result for key.
+	Add(key string, value any)
+}
+
+// WithCache reuses the results of earlier rows with the same arguments from c instead of calling the function again.
+// Only successful results are stored; rows that fail or panic are executed every time.
+// Building the UDF fails with ErrVolatileCache if it is volatile.
+//
+//	sf, err := udf.BuildScalarUDF(parseUserAgent, udf.WithCache(udf.LRU(10000)))
+func WithCache(c Cache) func(*udfOption) {
+	return func(o *udfOption) {
+		o.cache = c
+	}
+}
+
+// CacheStats are the counters of an LRUCache.
+type CacheStats struct {
+	Hits      int64 // Lookups that found a result
+	Misses    int64 // Lookups that found no result, including expired ones
+	Evictions int64 // Results removed to make room or because they expired
+}
+
+// LRUCache is a Cache holding a limited number of results. When it is full, the least recently used result is evicted.
+type LRUCache struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time // Replaced in tests
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   list.List // Most recently used at the front, elements hold *cacheEntry
+
+	hits, misses, evictions atomic.Int64
+}
+
+type cacheEntry struct {
+	key     string
+	value   any
+	expires time.Time // Zero if the entry does not expire
+}
+
+// LRU returns an LRUCache holding up to n results. A non-positive n is treated as 1.
+func LRU(n int) *LRUCache {
+	return LRUWithTTL(n, 0)
+}
+
+// LRUWithTTL returns an LRUCache holding up to n results for at most ttl each, for functions whose
+// results change slowly over time, such as lookups in external data. A non-positive ttl never expires results.
+func LRUWithTTL(n int, ttl time.Duration) *LRUCache {
+	return &LRUCache{size: max(n, 1), ttl: ttl, now: time.Now, entries: make(map[string]*list.Element)}
+}
+
+// Get implements Cache.
+func (c *LRUCache) Get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !entry.expires.IsZero() && !c.now().Before(entry.expires) {
+		c.remove(elem)
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	c.hits.Add(1)
+	return entry.value, true
+}
+
+// Add implements Cache.
+func (c *LRUCache) Add(key string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &cacheEntry{key: key, value: value}
+	if c.ttl > 0 {
+		entry.expires = c.now().Add(c.ttl)
+	}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	if c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove evicts elem. c.mu must be held.
+func (c *LRUCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+	c.evictions.Add(1)
+}
+
+// Len returns the number of stored results, including expired ones that have not been evicted yet.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Stats returns the hit, miss and eviction counters.
+func (c *LRUCache) Stats() CacheStats {
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load(), Evictions: c.evictions.Load()}
+}
+
+// cacheKey encodes the argument values of a row. DuckDB passes the same Go types for the arguments
+// of a function on every row, and converting them to the parameter types is deterministic,
+// so equal keys mean equal converted arguments.
+func cacheKey(args []driver.Value) string {
+	b := make([]byte, 0, 64)
+	for _, arg := range args {
+		b = appendCacheKey(b, arg)
+	}
+	return string(b)
+}
+
+// appendCacheKey appends a type-tagged encoding of v to b.
+func appendCacheKey(b []byte, v any) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 'n')
+	case bool:
+		if v {
+			return append(b, 't')
+		}
+		return append(b, 'f')
+	case int8:
+		return binary.AppendVarint(append(b, 'i'), int64(v))
+	case int16:
+		return binary.AppendVarint(append(b, 'i'), int64(v))
+	case int32:
+		return binary.AppendVarint(append(b, 'i'), int64(v))
+	case int64:
+		return binary.AppendVarint(append(b, 'i'), v)
+	case uint8:
+		return binary.AppendUvarint(append(b, 'u'), uint64(v))
+	case uint16:
+		return binary.AppendUvarint(append(b, 'u'), uint64(v))
+	case uint32:
+		return binary.AppendUvarint(append(b, 'u'), uint64(v))
+	case uint64:
+		return binary.AppendUvarint(append(b, 'u'), v)
+	case float32:
+		return binary.AppendUvarint(append(b, 'd'), math.Float64bits(float64(v)))
+	case float64:
+		return binary.AppendUvarint(append(b, 'd'), math.Float64bits(v))
+	case string:
+		return append(binary.AppendUvarint(append(b, 's'), uint64(len(v))), v...)
+	case []byte:
+		return append(binary.AppendUvarint(append(b, 'b'), uint64(len(v))), v...)
+	case time.Time:
+		b = binary.AppendVarint(append(b, 'T'), v.Unix())
+		return binary.AppendVarint(b, int64(v.Nanosecond()))
+	case []any:
+		b = binary.AppendUvarint(append(b, 'l'), uint64(len(v)))
+		for _, e := range v {
+			b = appendCacheKey(b, e)
+		}
+		return b
+	case map[string]any: // STRUCT
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		b = binary.AppendUvarint(append(b, 'm'), uint64(len(keys)))
+		for _, k := range keys {
+			b = appendCacheKey(appendCacheKey(b, k), v[k])
+		}
+		return b
+	default: // MAP, UUID, INTERVAL, DECIMAL and other rare types
+		s := fmt.Sprintf("%T:%#v", v, v)
+		return append(binary.AppendUvarint(append(b, 'x'), uint64(len(s))), s...)
+	}
+}
@@ -0,0 +1,127 @@
+package udf
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+func TestWithCache(t *testing.T) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get DB connection: %v", err)
+	}
+	defer conn.Close()
+
+	var calls atomic.Int64
+	square := func(x int64) int64 {
+		calls.Add(1)
+		if x < 0 {
+			panic("negative")
+		}
+		return x * x
+	}
+	cache := LRU(10)
+	sf, err := BuildScalarUDF(square, WithCache(cache))
+	if err != nil {
+		t.Fatalf("BuildScalarUDF failed: %v", err)
+	}
+	if err := duckdb.RegisterScalarUDF(conn, "cached_square", sf); err != nil {
+		t.Fatalf("Failed to register UDF: %v", err)
+	}
+
+	// Fewer rows than a vector, so that a single thread executes them
+	result := querySingleValueOnConn(t, conn, "SELECT sum(cached_square(i % 3))::BIGINT FROM range(1000) t(i)")
+	if want := int64(333*1 + 333*4); result != want {
+		t.Errorf("sum = %v, want %d", result, want)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("function called %d times, want 3", n)
+	}
+	if stats := cache.Stats(); stats.Hits != 997 || stats.Misses != 3 {
+		t.Errorf("stats = %+v, want 997 hits and 3 misses", stats)
+	}
+
+	// Failed rows are not cached
+	for range 2 {
+		expectQueryErrorOnConn(t, conn, "negative", "SELECT cached_square(x) FROM (VALUES (-1)) t(x)")
+	}
+	if n := calls.Load(); n != 5 {
+		t.Errorf("function called %d times, want 5", n)
+	}
+
+	_, err = BuildScalarUDF(square, WithCache(LRU(10)), WithVolatile(true))
+	if !errors.Is(err, ErrVolatileCache) {
+		t.Errorf("expected ErrVolatileCache for a volatile function, got %v", err)
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := LRUWithTTL(2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get(a) = %v, %v", v, ok)
+	}
+	c.Add("c", 3) // Evicts b, the least recently used
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", c.Len())
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Error("a should have expired")
+	}
+	want := CacheStats{Hits: 1, Misses: 2, Evictions: 2}
+	if stats := c.Stats(); stats != want {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	distinct := [][]driver.Value{
+		{"ab", "c"},
+		{"a", "bc"},
+		{nil, "abc"},
+		{"", "abc"},
+		{int32(1), "abc"},
+		{uint32(1), "abc"},
+		{[]any{int32(1), int32(2)}, "abc"},
+		{[]any{[]any{int32(1)}, int32(2)}, "abc"},
+		{map[string]any{"a": int32(1)}, "abc"},
+		{map[string]any{"a": int32(2)}, "abc"},
+		{time.Unix(1, 0), "abc"},
+		{time.Unix(1, 1), "abc"},
+	}
+	seen := map[string]int{}
+	for i, args := range distinct {
+		key := cacheKey(args)
+		if j, ok := seen[key]; ok {
+			t.Errorf("arguments %v and %v have the same key", distinct[j], args)
+		}
+		seen[key] = i
+	}
+
+	a := cacheKey([]driver.Value{map[string]any{"x": int32(1), "y": "s", "z": []byte("b")}})
+	b := cacheKey([]driver.Value{map[string]any{"z": []byte("b"), "y": "s", "x": int32(1)}})
+	if a != b {
+		t.Error("equal structs have different keys")
+	}
+}
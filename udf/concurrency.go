@@ -0,0 +1,27 @@
+package udf
+
+// WithMaxConcurrency allows at most n calls of the user function to run at the same time.
+// DuckDB executes a UDF on several worker threads at once; further calls wait until a running call returns.
+// With WithTimeout, the wait counts toward the timeout, and an abandoned call keeps its slot until it returns.
+// A zero or negative n allows any number of concurrent calls (default).
+func WithMaxConcurrency(n int) func(*udfOption) {
+	return func(o *udfOption) {
+		o.maxConcurrency = n
+	}
+}
+
+// WithSerialized runs the calls of the user function one at a time, for functions that are not safe
+// for concurrent use, e.g. because they modify shared state without synchronization.
+// It is equivalent to WithMaxConcurrency(1).
+func WithSerialized() func(*udfOption) {
+	return WithMaxConcurrency(1)
+}
+
+// limitConcurrency wraps call so that it holds a slot of the concurrency limit while it runs.
+func (asf *autoScalarFunc) limitConcurrency(call func() any) func() any {
+	return func() any {
+		asf.slots <- struct{}{}
+		defer func() { <-asf.slots }()
+		return call()
+	}
+}
@@ -0,0 +1,70 @@
+package udf
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+func TestWithMaxConcurrency(t *testing.T) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get DB connection: %v", err)
+	}
+	defer conn.Close()
+	// Enough rows for DuckDB to scan the table on several threads
+	if _, err := conn.ExecContext(context.Background(), "SET threads = 4; CREATE TABLE nums AS SELECT i::BIGINT AS x FROM range(130000) t(i)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	testCases := []struct {
+		name string
+		opts []Option
+		max  int64
+	}{
+		{"serialized", []Option{WithSerialized()}, 1},
+		{"two", []Option{WithMaxConcurrency(2)}, 2},
+		{"two with timeout", []Option{WithMaxConcurrency(2), WithTimeout(time.Minute)}, 2},
+	}
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var running, maxRunning atomic.Int64
+			fn := func(x int64) int64 {
+				n := running.Add(1)
+				defer running.Add(-1)
+				for {
+					old := maxRunning.Load()
+					if n <= old || maxRunning.CompareAndSwap(old, n) {
+						break
+					}
+				}
+				return x % 7
+			}
+			name := fmt.Sprintf("limited_%d", i)
+			sf, err := BuildScalarUDF(fn, tc.opts...)
+			if err != nil {
+				t.Fatalf("BuildScalarUDF failed: %v", err)
+			}
+			if err := duckdb.RegisterScalarUDF(conn, name, sf); err != nil {
+				t.Fatalf("Failed to register UDF: %v", err)
+			}
+			result := querySingleValueOnConn(t, conn, fmt.Sprintf("SELECT count(*) FILTER (WHERE %s(x) = x %% 7) FROM nums", name))
+			if result != int64(130000) {
+				t.Errorf("expected 130000 matching rows, got %v", result)
+			}
+			if m := maxRunning.Load(); m < 1 || m > tc.max {
+				t.Errorf("up to %d calls ran at once, want at most %d", m, tc.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package udf
+
+import (
+	"database/sql/driver"
+	"errors"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+// ErrVolatileDedup is the reason of the BuildError returned when WithChunkDedup(true) is combined with WithVolatile(true).
+// Volatile functions must be called for every row, even if the arguments repeat.
+var ErrVolatileDedup = errors.New("calls of volatile functions cannot be deduplicated")
+
+// WithChunkDedup sets whether to call the function once per distinct list of arguments in each chunk of rows,
+// typically 2048, that DuckDB passes to the UDF, reusing the result for the other rows of the chunk.
+// This pays off for constant arguments, such as my_fn(col, 'config'), and for low-cardinality columns,
+// at the cost of building a key from the arguments of every row. Unlike WithCache, nothing is kept between
+// chunks, so no memory has to be bounded. Only the calls made are reported to observers.
+// Building the UDF fails with ErrVolatileDedup if it is volatile.
+//
+//	sf, err := udf.BuildScalarUDF(parseUserAgent, udf.WithChunkDedup(true))
+func WithChunkDedup(enabled bool) func(*udfOption) {
+	return func(o *udfOption) {
+		o.chunkDedup = enabled
+	}
+}
+
+// dedupRows returns a RowExecutorFn calling execute once per distinct list of arguments.
+// duckdb-go gets the Executor of a UDF for every chunk, so each chunk has its own results.
+func dedupRows(execute duckdb.RowExecutorFn) duckdb.RowExecutorFn {
+	results := make(map[string]any)
+	return func(inputArgs []driver.Value) (any, error) {
+		key := cacheKey(inputArgs)
+		if result, ok := results[key]; ok {
+			return result, nil
+		}
+		result, err := execute(inputArgs)
+		if err == nil {
+			results[key] = result
+		}
+		return result, err
+	}
+}
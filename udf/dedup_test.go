@@ -0,0 +1,85 @@
+package udf
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithChunkDedup(t *testing.T) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get DB connection: %v", err)
+	}
+	defer conn.Close()
+
+	var calls atomic.Int64
+	scale := func(x int64, unit string) int64 {
+		calls.Add(1)
+		if unit == "k" {
+			return x * 1000
+		}
+		return x
+	}
+	if err := Register(conn, "scale", scale, WithChunkDedup(true)); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	// Fewer rows than a vector, so that they form a single chunk
+	result := querySingleValueOnConn(t, conn, "SELECT sum(scale(i % 4, 'k'))::BIGINT FROM range(1000) t(i)")
+	if want := int64(250 * (0 + 1 + 2 + 3) * 1000); result != want {
+		t.Errorf("sum = %v, want %d", result, want)
+	}
+	if n := calls.Swap(0); n != 4 {
+		t.Errorf("function called %d times, want 4", n)
+	}
+
+	// Results are not kept between chunks
+	result = querySingleValueOnConn(t, conn, "SELECT sum(scale(i % 4, 'k'))::BIGINT FROM range(100000) t(i)")
+	if want := int64(25000 * 6 * 1000); result != want {
+		t.Errorf("sum = %v, want %d", result, want)
+	}
+	if n := calls.Swap(0); n < 4*(100000/2048) || n > 4000 {
+		t.Errorf("function called %d times, want 4 per chunk", n)
+	}
+
+	// Different arguments are not mixed up, including NULL and the constant argument
+	rows, err := conn.QueryContext(context.Background(),
+		"SELECT scale(x, u) FROM (VALUES (1, 'k'), (1, 'm'), (1, 'k'), (NULL, 'k'), (2, 'm')) t(x, u)")
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	var got []any
+	for rows.Next() {
+		var v any
+		if err := rows.Scan(&v); err != nil {
+			t.Fatalf("scan failed: %v", err)
+		}
+		got = append(got, v)
+	}
+	rows.Close()
+	want := []any{int64(1000), int64(1), int64(1000), nil, int64(2)}
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("results = %v, want %v", got, want)
+		}
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("function called %d times, want 3", n)
+	}
+}
+
+func TestWithChunkDedupVolatile(t *testing.T) {
+	_, err := BuildScalarUDF(func(x int) int { return x }, WithChunkDedup(true), WithVolatile(true))
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) || !errors.Is(err, ErrVolatileDedup) {
+		t.Errorf("expected a BuildError wrapping ErrVolatileDedup, got %v", err)
+	}
+}
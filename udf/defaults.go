@@ -0,0 +1,105 @@
+package udf
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/ma6174/duckgo/internal/convert"
+)
+
+// WithDefaults makes the last len(values) parameters of the function optional in SQL: values[i] is passed
+// for the i-th of them when a call omits it. Only trailing arguments can be omitted; DuckDB scalar functions
+// have no named arguments. Each value must be convertible to its parameter type like a value passed from SQL;
+// nil is allowed for pointer, map and struct parameters. Variadic functions cannot have defaults.
+// The default of a parameter with WithPrepare is a value of the SQL argument, which is prepared once when building.
+//
+// The defaults are implemented as overloads of the function, one per number of omitted arguments,
+// so the function must be registered with BuildScalarUDFSet and duckdb.RegisterScalarUDFSet, or with Register:
+//
+//	formatMoney := func(amount float64, currency string, decimals int) string { ... }
+//	err := udf.Register(conn, "format_money", formatMoney, udf.WithDefaults("USD", 2))
+//	// SELECT format_money(12.5), format_money(12.5, 'EUR'), format_money(12.5, 'JPY', 0)
+//
+// BuildScalarUDF builds only the overload taking all arguments.
+func WithDefaults(values ...any) func(*udfOption) {
+	return func(o *udfOption) {
+		o.defaults = values
+	}
+}
+
+// convertDefaults converts the default values of the trailing parameters of asf to their Go types.
+func (asf *autoScalarFunc) convertDefaults(values []any) error {
+	funcType := asf.userFunc.Type()
+	if asf.isVariadic {
+		return &BuildError{GoType: funcType, FuncType: funcType,
+			Reason: errors.New("variadic functions cannot have default values")}
+	}
+	first := len(asf.goArgTypes) - len(values)
+	if first < 0 {
+		return &BuildError{GoType: funcType, FuncType: funcType,
+			Reason: fmt.Errorf("%d default values given for a function with %d parameters", len(values), len(asf.goArgTypes))}
+	}
+	asf.defaults = make([]driver.Value, len(values))
+	for i, v := range values {
+		if p, ok := asf.prepared[first+i]; ok && v != nil {
+			// Prepared once here, from the SQL input type of the prepare function
+			prepared, err := p.prepare(v)
+			if err != nil {
+				return &BuildError{Param: fmt.Sprintf("argument %d", first+i), GoType: p.inputType, FuncType: funcType,
+					Reason: fmt.Errorf("invalid default value: %w", err)}
+			}
+			asf.defaults[i] = prepared
+			continue
+		}
+		argType := asf.goArgTypes[first+i]
+		converted, err := convert.FromDuckDB(v, argType)
+		if err != nil {
+			return &BuildError{Param: fmt.Sprintf("argument %d", first+i), GoType: argType, FuncType: funcType,
+				Reason: fmt.Errorf("invalid default value: %w", err)}
+		}
+		asf.defaults[i] = converted.Interface()
+	}
+	return nil
+}
+
+// withDefaultArgs appends the defaults of the arguments omitted by this overload to inputArgs.
+func (asf *autoScalarFunc) withDefaultArgs(inputArgs []driver.Value) []driver.Value {
+	return append(slices.Clip(inputArgs), asf.defaults[len(asf.defaults)-asf.omitted:]...)
+}
+
+// BuildScalarUDFSet builds a UDF like BuildScalarUDF, returning one overload for every number of trailing
+// arguments that can be omitted because of WithDefaults, starting with the one taking all arguments.
+// Without defaults, it returns the single function built by BuildScalarUDF.
+// The overloads share the cache, concurrency limit and observers configured by opts.
+func BuildScalarUDFSet(fn any, opts ...func(*udfOption)) ([]duckdb.ScalarFunc, error) {
+	sf, err := BuildScalarUDF(fn, opts...)
+	if err != nil {
+		return nil, err
+	}
+	asf := sf.(*autoScalarFunc)
+	set := []duckdb.ScalarFunc{asf}
+	for omitted := 1; omitted <= len(asf.defaults); omitted++ {
+		overload := *asf
+		overload.duckDBInputTypeInfos = asf.duckDBInputTypeInfos[:len(asf.duckDBInputTypeInfos)-omitted]
+		overload.omitted = omitted
+		set = append(set, &overload)
+	}
+	return set, nil
+}
+
+// Register builds fn with BuildScalarUDFSet and registers it on conn under name, also setting
+// WithName(name) unless opts set another name. Functions with WithDefaults are registered as a set of overloads.
+func Register(conn *sql.Conn, name string, fn any, opts ...func(*udfOption)) error {
+	set, err := BuildScalarUDFSet(fn, append([]func(*udfOption){WithName(name)}, opts...)...)
+	if err != nil {
+		return err
+	}
+	if len(set) == 1 {
+		return duckdb.RegisterScalarUDF(conn, name, set[0])
+	}
+	return duckdb.RegisterScalarUDFSet(conn, name, set...)
+}
@@ -0,0 +1,163 @@
+package udf
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithDefaults(t *testing.T) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get DB connection: %v", err)
+	}
+	defer conn.Close()
+
+	formatMoney := func(amount float64, currency string, decimals int) string {
+		return fmt.Sprintf("%.*f %s", decimals, amount, currency)
+	}
+	if err := Register(conn, "format_money", formatMoney, WithDefaults("USD", 2)); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	greet := func(name string, title *string) string {
+		if title == nil {
+			return "Hello " + name
+		}
+		return "Hello " + *title + " " + name
+	}
+	if err := Register(conn, "greet", greet, WithDefaults(nil), WithSpecialNullHandling(true)); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := Register(conn, "no_defaults", func(a int32) int32 { return a * 2 }); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	tests := []struct {
+		query string
+		want  any
+	}{
+		{"SELECT format_money(12.5)", "12.50 USD"},
+		{"SELECT format_money(12.5, 'EUR')", "12.50 EUR"},
+		{"SELECT format_money(12.5, 'JPY', 0)", "12 JPY"},
+		{"SELECT format_money(a) FROM (VALUES (1.0), (2.0)) t(a) ORDER BY a DESC LIMIT 1", "2.00 USD"},
+		{"SELECT greet('Ada')", "Hello Ada"},
+		{"SELECT greet('Ada', 'Dr.')", "Hello Dr. Ada"},
+		{"SELECT no_defaults(21)", int32(42)},
+	}
+	for _, tt := range tests {
+		if got := querySingleValueOnConn(t, conn, tt.query); got != tt.want {
+			t.Errorf("%s = %#v, want %#v", tt.query, got, tt.want)
+		}
+	}
+	expectQueryErrorOnConn(t, conn, "format_money", "SELECT format_money()")
+}
+
+func TestBuildScalarUDFSet(t *testing.T) {
+	set, err := BuildScalarUDFSet(func(a, b, c int) int { return a + b + c }, WithDefaults(2, 3))
+	if err != nil {
+		t.Fatalf("BuildScalarUDFSet failed: %v", err)
+	}
+	if len(set) != 3 {
+		t.Fatalf("expected 3 overloads, got %d", len(set))
+	}
+	for i, sf := range set {
+		if n := len(sf.Config().InputTypeInfos); n != 3-i {
+			t.Errorf("overload %d takes %d arguments, want %d", i, n, 3-i)
+		}
+	}
+	result, err := set[2].Executor().RowExecutor([]driver.Value{int32(1)})
+	if err != nil || result != 6 {
+		t.Errorf("calling overload with defaults = %v, %v, want 6", result, err)
+	}
+	if _, err := set[1].Executor().RowExecutor([]driver.Value{int32(1)}); err == nil {
+		t.Error("expected an error for a wrong number of arguments")
+	}
+
+	set, err = BuildScalarUDFSet(func(a int) int { return a })
+	if err != nil || len(set) != 1 {
+		t.Fatalf("expected a single function without defaults, got %d, %v", len(set), err)
+	}
+}
+
+func TestWithDefaultsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       any
+		defaults []any
+		param    string
+		errMsg   string
+	}{
+		{"variadic", func(sep string, s ...string) string { return sep }, []any{","}, "", "variadic functions cannot have default values"},
+		{"too many", func(a int) int { return a }, []any{1, 2}, "", "2 default values given for a function with 1 parameters"},
+		{"wrong type", func(a int, b string) string { return b }, []any{42}, "argument 1", "invalid default value"},
+		{"nil for value", func(a int, b int) int { return b }, []any{nil}, "argument 1", "cannot convert nil"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := BuildScalarUDF(tt.fn, WithDefaults(tt.defaults...))
+			var buildErr *BuildError
+			if !errors.As(err, &buildErr) {
+				t.Fatalf("expected *BuildError, got %v", err)
+			}
+			if buildErr.Param != tt.param {
+				t.Errorf("Param = %q, want %q", buildErr.Param, tt.param)
+			}
+			expectError(t, err, tt.errMsg)
+		})
+	}
+}
+
+func TestWithDefaultsPrepared(t *testing.T) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get DB connection: %v", err)
+	}
+	defer conn.Close()
+
+	var compiles atomic.Int64
+	compile := func(pattern string) (*regexp.Regexp, error) {
+		compiles.Add(1)
+		return regexp.Compile(pattern)
+	}
+	match := func(s string, re *regexp.Regexp) bool { return re.MatchString(s) }
+	if err := Register(conn, "starts_with_a", match, WithPrepare(1, compile), WithDefaults("^a")); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if n := compiles.Swap(0); n != 1 {
+		t.Errorf("default compiled %d times when building, want 1", n)
+	}
+
+	result := querySingleValueOnConn(t, conn,
+		"SELECT count(*) FILTER (WHERE starts_with_a(s)) FROM (VALUES ('abc'), ('bca'), ('a')) t(s)")
+	if result != int64(2) {
+		t.Errorf("count = %v, want 2", result)
+	}
+	if result := querySingleValueOnConn(t, conn, "SELECT starts_with_a('bca', 'c')"); result != true {
+		t.Errorf("starts_with_a('bca', 'c') = %v, want true", result)
+	}
+	if n := compiles.Load(); n != 1 {
+		t.Errorf("pattern compiled %d times by queries, want 1 for the explicit argument", n)
+	}
+
+	_, err = BuildScalarUDFSet(match, WithPrepare(1, regexp.Compile), WithDefaults("("))
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) || buildErr.Param != "argument 1" {
+		t.Fatalf("expected a BuildError for argument 1, got %v", err)
+	}
+	expectError(t, err, "invalid default value")
+}
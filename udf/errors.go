@@ -0,0 +1,77 @@
+package udf
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// BuildError is returned by BuildScalarUDF when a Go function cannot be turned into a UDF.
+// Use errors.As to inspect it.
+type BuildError struct {
+	// Param identifies the offending part of the signature: "argument N" for the N-th fixed argument,
+	// "variadic" for the element type of the variadic argument, "result" for the return value,
+	// or "" if the function as a whole is unsuitable (not a function, wrong number of results).
+	Param string
+	// GoType is the offending Go type: the type of the parameter, or of fn itself if Param is empty.
+	GoType reflect.Type
+	// FuncType is the type of the function passed to BuildScalarUDF.
+	FuncType reflect.Type
+	// Reason describes why the type is not supported.
+	Reason error
+}
+
+func (e *BuildError) Error() string {
+	switch {
+	case e.Param == "":
+		return fmt.Sprintf("BuildScalarUDF: %v", e.Reason)
+	case e.Param == "variadic":
+		return fmt.Sprintf("BuildScalarUDF: error converting Go variadic element type for UDF (Go type %s, func type %s): %v",
+			e.GoType.String(), e.FuncType.String(), e.Reason)
+	case e.Param == "result":
+		return fmt.Sprintf("BuildScalarUDF: error converting Go return type for UDF (Go type %s, func type %s): %v",
+			e.GoType.String(), e.FuncType.String(), e.Reason)
+	default:
+		return fmt.Sprintf("BuildScalarUDF: error converting Go type for fixed %s of UDF (Go type %s, func type %s): %v",
+			e.Param, e.GoType.String(), e.FuncType.String(), e.Reason)
+	}
+}
+
+func (e *BuildError) Unwrap() error {
+	return e.Reason
+}
+
+// ConversionError is returned during execution when a value passed by DuckDB cannot be converted
+// to the Go type of the corresponding parameter. Use errors.As to inspect it.
+type ConversionError struct {
+	// FuncName is the SQL name of the UDF, as set by WithName. It is empty if no name was set.
+	FuncName string
+	// FuncType is the type of the Go function.
+	FuncType reflect.Type
+	// ArgIndex is the position of the argument in the SQL call, starting at 0.
+	ArgIndex int
+	// Variadic is true if the argument belongs to the variadic part of the call.
+	Variadic bool
+	// SourceType is the Go type of the value received from DuckDB, or nil for SQL NULL.
+	SourceType reflect.Type
+	// TargetType is the Go type of the parameter (the element type for variadic arguments).
+	TargetType reflect.Type
+	// Reason describes why the conversion failed.
+	Reason error
+}
+
+func (e *ConversionError) Error() string {
+	prefix := ""
+	if e.FuncName != "" {
+		prefix = fmt.Sprintf("UDF %s: ", e.FuncName)
+	}
+	if e.Variadic {
+		return fmt.Sprintf("%serror converting variadic parameter (overall input arg %d, Go element type %s, func type %s): %v",
+			prefix, e.ArgIndex, e.TargetType.String(), e.FuncType.String(), e.Reason)
+	}
+	return fmt.Sprintf("%serror converting parameter %d (Go type %s, func type %s): %v",
+		prefix, e.ArgIndex, e.TargetType.String(), e.FuncType.String(), e.Reason)
+}
+
+func (e *ConversionError) Unwrap() error {
+	return e.Reason
+}
@@ -0,0 +1,90 @@
+package udf
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildErrorDetails(t *testing.T) {
+	testCases := []struct {
+		name      string
+		fn        any
+		wantParam string
+		wantType  reflect.Type
+	}{
+		{"not a function", 42, "", reflect.TypeOf(0)},
+		{"fixed argument", func(s string, c chan int) string { return s }, "argument 1", reflect.TypeOf(make(chan int))},
+		{"variadic element", func(cs ...chan int) int { return 0 }, "variadic", reflect.TypeOf(make(chan int))},
+		{"result", func() chan int { return nil }, "result", reflect.TypeOf(make(chan int))},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := BuildScalarUDF(tc.fn)
+			var buildErr *BuildError
+			if !errors.As(err, &buildErr) {
+				t.Fatalf("expected *BuildError, got %T: %v", err, err)
+			}
+			if buildErr.Param != tc.wantParam {
+				t.Errorf("Param = %q, want %q", buildErr.Param, tc.wantParam)
+			}
+			if buildErr.GoType != tc.wantType {
+				t.Errorf("GoType = %v, want %v", buildErr.GoType, tc.wantType)
+			}
+			if buildErr.FuncType != reflect.TypeOf(tc.fn) {
+				t.Errorf("FuncType = %v, want %v", buildErr.FuncType, reflect.TypeOf(tc.fn))
+			}
+		})
+	}
+}
+
+func TestConversionErrorDetails(t *testing.T) {
+	testCases := []struct {
+		name         string
+		fn           any
+		args         []driver.Value
+		wantIndex    int
+		wantVariadic bool
+		wantSource   reflect.Type
+		wantTarget   reflect.Type
+	}{
+		{"fixed", func(a int32, b int32) int32 { return a + b }, []driver.Value{int32(1), "two"}, 1, false, reflect.TypeOf(""), reflect.TypeOf(int32(0))},
+		{"variadic", func(sep string, xs ...int64) int64 { return 0 }, []driver.Value{",", int64(1), []byte("x")}, 2, true, reflect.TypeOf([]byte(nil)), reflect.TypeOf(int64(0))},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sf, err := BuildScalarUDF(tc.fn, WithName("my_func"))
+			if err != nil {
+				t.Fatalf("BuildScalarUDF failed: %v", err)
+			}
+			_, err = sf.Executor().RowExecutor(tc.args)
+			var convErr *ConversionError
+			if !errors.As(err, &convErr) {
+				t.Fatalf("expected *ConversionError, got %T: %v", err, err)
+			}
+			if convErr.FuncName != "my_func" || convErr.ArgIndex != tc.wantIndex || convErr.Variadic != tc.wantVariadic {
+				t.Errorf("got FuncName=%q ArgIndex=%d Variadic=%t, want my_func/%d/%t",
+					convErr.FuncName, convErr.ArgIndex, convErr.Variadic, tc.wantIndex, tc.wantVariadic)
+			}
+			if convErr.SourceType != tc.wantSource || convErr.TargetType != tc.wantTarget {
+				t.Errorf("got SourceType=%v TargetType=%v, want %v/%v", convErr.SourceType, convErr.TargetType, tc.wantSource, tc.wantTarget)
+			}
+			if !strings.HasPrefix(err.Error(), "UDF my_func: ") {
+				t.Errorf("error %q does not name the function", err)
+			}
+		})
+	}
+}
+
+func TestWithNameInPanicMessage(t *testing.T) {
+	sf, err := BuildScalarUDF(func() int32 { panic("boom") }, WithName("exploding"))
+	if err != nil {
+		t.Fatalf("BuildScalarUDF failed: %v", err)
+	}
+	_, err = sf.Executor().RowExecutor(nil)
+	if err == nil || !strings.Contains(err.Error(), "panic in UDF exploding (func type func() int32): boom") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
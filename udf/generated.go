@@ -0,0 +1,61 @@
+package udf
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/ma6174/duckgo/internal/convert"
+)
+
+// GeneratedExecutor converts the arguments of one row and returns a function that calls the UDF with them.
+// Conversion errors are returned as *ConversionError, as returned by ConvertArg.
+// Executors are written by the duckgo gen code generator and passed to BuildGeneratedScalarUDF.
+type GeneratedExecutor func(args []driver.Value) (call func() any, err error)
+
+// BuildGeneratedScalarUDF builds a scalar UDF for fn that executes rows with exec instead of reflection.
+// It is called by code generated with "duckgo gen", which writes an exec calling fn directly.
+//
+// The UDF is configured from the signature of fn with the same options as BuildScalarUDF, and it behaves
+// the same way: exec only replaces the per-row argument conversion and function call, while argument count checks,
+// execution limits, result conversion and panic recovery are shared with the reflective path.
+func BuildGeneratedScalarUDF(fn any, exec GeneratedExecutor, opts ...Option) (duckdb.ScalarFunc, error) {
+	sf, err := BuildScalarUDF(fn, opts...)
+	if err != nil {
+		return nil, err
+	}
+	asf := sf.(*autoScalarFunc)
+	if asf.state != nil {
+		funcType := asf.userFunc.Type()
+		return nil, &BuildError{Param: "state", GoType: asf.state.stateType, FuncType: funcType,
+			Reason: errors.New("generated executors do not support WithState")}
+	}
+	asf.generated = exec
+	return asf, nil
+}
+
+// ConvertArg converts the argument at index i of a row to T, exactly like the reflective path does.
+// Generated executors call it for values that do not have the type DuckDB passes for T in the common case,
+// such as NULL, and for types without a generated fast path, such as structs and maps.
+// Variadic marks arguments of the variadic part of the call. Errors are returned as *ConversionError.
+func ConvertArg[T any](args []driver.Value, i int, variadic bool) (T, error) {
+	if v, ok := args[i].(T); ok {
+		return v, nil
+	}
+	var zero T
+	targetType := reflect.TypeFor[T]()
+	converted, err := convert.FromDuckDB(args[i], targetType)
+	if err != nil {
+		var sourceType reflect.Type
+		if args[i] != nil {
+			sourceType = reflect.TypeOf(args[i])
+		}
+		// FuncName and FuncType are filled in by the UDF calling the executor
+		return zero, &ConversionError{ArgIndex: i, Variadic: variadic, SourceType: sourceType, TargetType: targetType, Reason: err}
+	}
+	// The converted value may only be assignable to T, e.g. []byte for a named byte slice type
+	result := reflect.New(targetType).Elem()
+	result.Set(converted)
+	return result.Interface().(T), nil
+}
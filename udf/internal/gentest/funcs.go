@@ -0,0 +1,63 @@
+// Package gentest holds functions with UDF wrappers generated by "duckgo gen". Its tests check that the
+// generated UDFs behave exactly like the ones built with reflection.
+package gentest
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+//go:generate go run ../../../cmd/duckgo gen -func=Add,Concat,Scale,Describe,Lookup,AddDays,Deref,Divide,Fail,Shout,strings.Repeat
+
+// Label is a named string type.
+type Label string
+
+// Point is passed as a STRUCT.
+type Point struct {
+	X int32
+	Y int32
+}
+
+// Add adds two integers.
+func Add(a, b int) int { return a + b }
+
+// Concat joins parts with sep.
+func Concat(sep string, parts ...string) string { return strings.Join(parts, sep) }
+
+// Scale multiplies a FLOAT and returns a DOUBLE.
+func Scale(v float32, factor float64) float64 { return float64(v) * factor }
+
+// Describe formats a point.
+func Describe(label Label, p Point, data []byte, flag bool) string {
+	return string(label) + ":" + strings.Repeat("*", int(p.X+p.Y)) + ":" + string(data) + ":" + map[bool]string{true: "y", false: "n"}[flag]
+}
+
+// Lookup returns the value of key in m.
+func Lookup(m map[string]int64, key string) int64 { return m[key] }
+
+// AddDays moves t by days.
+func AddDays(t time.Time, days uint8) time.Time { return t.AddDate(0, 0, int(days)) }
+
+// Deref returns the value of p, or -1 for NULL.
+func Deref(p *int64) int64 {
+	if p == nil {
+		return -1
+	}
+	return *p
+}
+
+// Divide panics with a runtime error when b is 0.
+func Divide(a, b int) int { return a / b }
+
+// Fail panics with an error.
+func Fail(msg string) string { panic(errors.New(msg)) }
+
+// Shout upper-cases and joins all arguments.
+func Shout(words ...Label) string {
+	out := make([]string, len(words))
+	for i, w := range words {
+		out[i] = strings.ToUpper(string(w))
+	}
+	return strings.Join(out, " ")
+}
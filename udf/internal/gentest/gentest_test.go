@@ -0,0 +1,95 @@
+package gentest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/ma6174/duckgo/udf"
+)
+
+// TestGeneratedMatchesReflection registers every function twice, built with reflection and with the generated
+// wrapper, and checks that both return the same results and errors.
+func TestGeneratedMatchesReflection(t *testing.T) {
+	funcs := []struct {
+		name      string
+		fn        any
+		generated func(opts ...udf.Option) (duckdb.ScalarFunc, error)
+		queries   []string
+	}{
+		{"add", Add, AddUDF, []string{"%s(1, 2)", "%s(NULL, 2)", "%s(-7, 2147483647)"}},
+		{"concat", Concat, ConcatUDF, []string{"%s('-', 'a', 'b', 'c')", "%s('-')", "%s('-', 'a', NULL)", "%s(NULL, 'a')"}},
+		{"scale", Scale, ScaleUDF, []string{"%s(1.5::FLOAT, 2)", "%s(NULL, 2)"}},
+		{"describe", Describe, DescribeUDF, []string{"%s('p', {'X': 1, 'Y': 2}, 'ab'::BLOB, true)", "%s('p', NULL, NULL, false)"}},
+		{"lookup", Lookup, LookupUDF, []string{"%s(MAP {'a': 1::BIGINT, 'b': 2::BIGINT}, 'b')", "%s(NULL, 'a')"}},
+		{"add_days", AddDays, AddDaysUDF, []string{"%s(TIMESTAMP '2024-02-28 10:00:00', 2)", "%s(NULL, 1)"}},
+		{"deref", Deref, DerefUDF, []string{"%s(5::BIGINT)", "%s(NULL::BIGINT)"}},
+		{"divide", Divide, DivideUDF, []string{"%s(7, 2)", "%s(1, 0)"}},
+		{"fail", Fail, FailUDF, []string{"%s('boom')"}},
+		{"shout", Shout, ShoutUDF, []string{"%s('a', 'b')", "%s()", "%s('a', NULL)"}},
+		{"repeat", strings.Repeat, RepeatUDF, []string{"%s('ab', 3)", "%s('ab', -1)"}},
+	}
+
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get DB connection: %v", err)
+	}
+	defer conn.Close()
+
+	for _, f := range funcs {
+		reflective, err := udf.BuildScalarUDF(f.fn, udf.WithName(f.name))
+		if err != nil {
+			t.Fatalf("BuildScalarUDF(%s) failed: %v", f.name, err)
+		}
+		generated, err := f.generated(udf.WithName(f.name))
+		if err != nil {
+			t.Fatalf("generated wrapper for %s failed: %v", f.name, err)
+		}
+		if !reflect.DeepEqual(reflective.Config(), generated.Config()) {
+			t.Fatalf("%s: config differs:\nreflection: %+v\ngenerated:  %+v", f.name, reflective.Config(), generated.Config())
+		}
+		if err := duckdb.RegisterScalarUDF(conn, "r_"+f.name, reflective); err != nil {
+			t.Fatalf("Failed to register r_%s: %v", f.name, err)
+		}
+		if err := duckdb.RegisterScalarUDF(conn, "g_"+f.name, generated); err != nil {
+			t.Fatalf("Failed to register g_%s: %v", f.name, err)
+		}
+
+		for _, query := range f.queries {
+			t.Run(fmt.Sprintf(query, f.name), func(t *testing.T) {
+				want, wantErr := queryValue(conn, "SELECT "+fmt.Sprintf(query, "r_"+f.name))
+				got, gotErr := queryValue(conn, "SELECT "+fmt.Sprintf(query, "g_"+f.name))
+				if errorText(wantErr) != errorText(gotErr) {
+					t.Fatalf("errors differ:\nreflection: %v\ngenerated:  %v", wantErr, gotErr)
+				}
+				if !reflect.DeepEqual(want, got) {
+					t.Fatalf("results differ:\nreflection: %#v\ngenerated:  %#v", want, got)
+				}
+			})
+		}
+	}
+}
+
+func queryValue(conn *sql.Conn, query string) (any, error) {
+	var v any
+	err := conn.QueryRowContext(context.Background(), query).Scan(&v)
+	return v, err
+}
+
+// errorText returns the message of err without the stack trace, which differs between the two call paths.
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	msg, _, _ := strings.Cut(err.Error(), "Stack trace:")
+	return msg
+}
@@ -0,0 +1,233 @@
+// Code generated by duckgo gen; DO NOT EDIT.
+
+package gentest
+
+import (
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/ma6174/duckgo/udf"
+)
+
+// AddUDF builds a scalar UDF for Add that calls it without reflection.
+// It behaves like udf.BuildScalarUDF(Add, opts...).
+func AddUDF(opts ...udf.Option) (duckdb.ScalarFunc, error) {
+	return udf.BuildGeneratedScalarUDF(Add, func(args []driver.Value) (func() any, error) {
+		var err error
+		var a0 int
+		if v, ok := args[0].(int32); ok {
+			a0 = int(v)
+		} else if a0, err = udf.ConvertArg[int](args, 0, false); err != nil {
+			return nil, err
+		}
+		var a1 int
+		if v, ok := args[1].(int32); ok {
+			a1 = int(v)
+		} else if a1, err = udf.ConvertArg[int](args, 1, false); err != nil {
+			return nil, err
+		}
+		return func() any { return Add(a0, a1) }, nil
+	}, opts...)
+}
+
+// ConcatUDF builds a scalar UDF for Concat that calls it without reflection.
+// It behaves like udf.BuildScalarUDF(Concat, opts...).
+func ConcatUDF(opts ...udf.Option) (duckdb.ScalarFunc, error) {
+	return udf.BuildGeneratedScalarUDF(Concat, func(args []driver.Value) (func() any, error) {
+		var err error
+		var a0 string
+		if v, ok := args[0].(string); ok {
+			a0 = v
+		} else if a0, err = udf.ConvertArg[string](args, 0, false); err != nil {
+			return nil, err
+		}
+		variadic := make([]string, len(args)-1)
+		for i := range variadic {
+			if v, ok := args[1+i].(string); ok {
+				variadic[i] = v
+			} else if variadic[i], err = udf.ConvertArg[string](args, 1+i, true); err != nil {
+				return nil, err
+			}
+		}
+		return func() any { return Concat(a0, variadic...) }, nil
+	}, opts...)
+}
+
+// ScaleUDF builds a scalar UDF for Scale that calls it without reflection.
+// It behaves like udf.BuildScalarUDF(Scale, opts...).
+func ScaleUDF(opts ...udf.Option) (duckdb.ScalarFunc, error) {
+	return udf.BuildGeneratedScalarUDF(Scale, func(args []driver.Value) (func() any, error) {
+		var err error
+		var a0 float32
+		if v, ok := args[0].(float32); ok {
+			a0 = v
+		} else if a0, err = udf.ConvertArg[float32](args, 0, false); err != nil {
+			return nil, err
+		}
+		var a1 float64
+		if v, ok := args[1].(float64); ok {
+			a1 = v
+		} else if a1, err = udf.ConvertArg[float64](args, 1, false); err != nil {
+			return nil, err
+		}
+		return func() any { return Scale(a0, a1) }, nil
+	}, opts...)
+}
+
+// DescribeUDF builds a scalar UDF for Describe that calls it without reflection.
+// It behaves like udf.BuildScalarUDF(Describe, opts...).
+func DescribeUDF(opts ...udf.Option) (duckdb.ScalarFunc, error) {
+	return udf.BuildGeneratedScalarUDF(Describe, func(args []driver.Value) (func() any, error) {
+		var err error
+		var a0 Label
+		if v, ok := args[0].(string); ok {
+			a0 = Label(v)
+		} else if a0, err = udf.ConvertArg[Label](args, 0, false); err != nil {
+			return nil, err
+		}
+		var a1 Point
+		if a1, err = udf.ConvertArg[Point](args, 1, false); err != nil {
+			return nil, err
+		}
+		var a2 []byte
+		if v, ok := args[2].([]byte); ok {
+			a2 = v
+		} else if a2, err = udf.ConvertArg[[]byte](args, 2, false); err != nil {
+			return nil, err
+		}
+		var a3 bool
+		if v, ok := args[3].(bool); ok {
+			a3 = v
+		} else if a3, err = udf.ConvertArg[bool](args, 3, false); err != nil {
+			return nil, err
+		}
+		return func() any { return Describe(a0, a1, a2, a3) }, nil
+	}, opts...)
+}
+
+// LookupUDF builds a scalar UDF for Lookup that calls it without reflection.
+// It behaves like udf.BuildScalarUDF(Lookup, opts...).
+func LookupUDF(opts ...udf.Option) (duckdb.ScalarFunc, error) {
+	return udf.BuildGeneratedScalarUDF(Lookup, func(args []driver.Value) (func() any, error) {
+		var err error
+		var a0 map[string]int64
+		if a0, err = udf.ConvertArg[map[string]int64](args, 0, false); err != nil {
+			return nil, err
+		}
+		var a1 string
+		if v, ok := args[1].(string); ok {
+			a1 = v
+		} else if a1, err = udf.ConvertArg[string](args, 1, false); err != nil {
+			return nil, err
+		}
+		return func() any { return Lookup(a0, a1) }, nil
+	}, opts...)
+}
+
+// AddDaysUDF builds a scalar UDF for AddDays that calls it without reflection.
+// It behaves like udf.BuildScalarUDF(AddDays, opts...).
+func AddDaysUDF(opts ...udf.Option) (duckdb.ScalarFunc, error) {
+	return udf.BuildGeneratedScalarUDF(AddDays, func(args []driver.Value) (func() any, error) {
+		var err error
+		var a0 time.Time
+		if a0, err = udf.ConvertArg[time.Time](args, 0, false); err != nil {
+			return nil, err
+		}
+		var a1 uint8
+		if v, ok := args[1].(uint32); ok {
+			a1 = uint8(v)
+		} else if a1, err = udf.ConvertArg[uint8](args, 1, false); err != nil {
+			return nil, err
+		}
+		return func() any { return AddDays(a0, a1) }, nil
+	}, opts...)
+}
+
+// DerefUDF builds a scalar UDF for Deref that calls it without reflection.
+// It behaves like udf.BuildScalarUDF(Deref, opts...).
+func DerefUDF(opts ...udf.Option) (duckdb.ScalarFunc, error) {
+	return udf.BuildGeneratedScalarUDF(Deref, func(args []driver.Value) (func() any, error) {
+		var err error
+		var a0 *int64
+		if a0, err = udf.ConvertArg[*int64](args, 0, false); err != nil {
+			return nil, err
+		}
+		return func() any { return Deref(a0) }, nil
+	}, opts...)
+}
+
+// DivideUDF builds a scalar UDF for Divide that calls it without reflection.
+// It behaves like udf.BuildScalarUDF(Divide, opts...).
+func DivideUDF(opts ...udf.Option) (duckdb.ScalarFunc, error) {
+	return udf.BuildGeneratedScalarUDF(Divide, func(args []driver.Value) (func() any, error) {
+		var err error
+		var a0 int
+		if v, ok := args[0].(int32); ok {
+			a0 = int(v)
+		} else if a0, err = udf.ConvertArg[int](args, 0, false); err != nil {
+			return nil, err
+		}
+		var a1 int
+		if v, ok := args[1].(int32); ok {
+			a1 = int(v)
+		} else if a1, err = udf.ConvertArg[int](args, 1, false); err != nil {
+			return nil, err
+		}
+		return func() any { return Divide(a0, a1) }, nil
+	}, opts...)
+}
+
+// FailUDF builds a scalar UDF for Fail that calls it without reflection.
+// It behaves like udf.BuildScalarUDF(Fail, opts...).
+func FailUDF(opts ...udf.Option) (duckdb.ScalarFunc, error) {
+	return udf.BuildGeneratedScalarUDF(Fail, func(args []driver.Value) (func() any, error) {
+		var err error
+		var a0 string
+		if v, ok := args[0].(string); ok {
+			a0 = v
+		} else if a0, err = udf.ConvertArg[string](args, 0, false); err != nil {
+			return nil, err
+		}
+		return func() any { return Fail(a0) }, nil
+	}, opts...)
+}
+
+// ShoutUDF builds a scalar UDF for Shout that calls it without reflection.
+// It behaves like udf.BuildScalarUDF(Shout, opts...).
+func ShoutUDF(opts ...udf.Option) (duckdb.ScalarFunc, error) {
+	return udf.BuildGeneratedScalarUDF(Shout, func(args []driver.Value) (func() any, error) {
+		var err error
+		variadic := make([]Label, len(args))
+		for i := range variadic {
+			if v, ok := args[i].(string); ok {
+				variadic[i] = Label(v)
+			} else if variadic[i], err = udf.ConvertArg[Label](args, i, true); err != nil {
+				return nil, err
+			}
+		}
+		return func() any { return Shout(variadic...) }, nil
+	}, opts...)
+}
+
+// RepeatUDF builds a scalar UDF for strings.Repeat that calls it without reflection.
+// It behaves like udf.BuildScalarUDF(strings.Repeat, opts...).
+func RepeatUDF(opts ...udf.Option) (duckdb.ScalarFunc, error) {
+	return udf.BuildGeneratedScalarUDF(strings.Repeat, func(args []driver.Value) (func() any, error) {
+		var err error
+		var a0 string
+		if v, ok := args[0].(string); ok {
+			a0 = v
+		} else if a0, err = udf.ConvertArg[string](args, 0, false); err != nil {
+			return nil, err
+		}
+		var a1 int
+		if v, ok := args[1].(int32); ok {
+			a1 = int(v)
+		} else if a1, err = udf.ConvertArg[int](args, 1, false); err != nil {
+			return nil, err
+		}
+		return func() any { return strings.Repeat(a0, a1) }, nil
+	}, opts...)
+}
@@ -0,0 +1,122 @@
+package udf
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// ErrLimitExceeded is wrapped by every error reporting that a UDF call went over one of its execution limits.
+// Code that enforces its own limits inside a user function (e.g. an interpreter step budget) can panic with
+// an error wrapping ErrLimitExceeded to have the violation handled according to the UDF's LimitPolicy.
+var ErrLimitExceeded = errors.New("UDF execution limit exceeded")
+
+// LimitPolicy controls what happens to a row whose UDF call exceeds an execution limit.
+type LimitPolicy int
+
+const (
+	// LimitFail fails the query with an error wrapping ErrLimitExceeded (default).
+	LimitFail LimitPolicy = iota
+	// LimitReturnNull returns SQL NULL for the offending row and continues with the next one.
+	LimitReturnNull
+)
+
+// callResult carries the outcome of a user function call made on a separate goroutine.
+type callResult struct {
+	result     any
+	panicValue any
+	stackTrace string
+	panicked   bool
+}
+
+// callWithTimeout calls the user function on a separate goroutine and waits at most asf.timeout for it to return.
+// A panic inside the user function is captured together with its stack trace and handed back to the caller,
+// because it cannot be recovered from the calling goroutine. If the call does not return in time,
+// an error wrapping ErrLimitExceeded is returned.
+func (asf *autoScalarFunc) callWithTimeout(call func() any) (callResult, error) {
+	done := make(chan callResult, 1) // Buffered so an abandoned call can still finish and exit
+	go func() {
+		var res callResult
+		defer func() {
+			if r := recover(); r != nil {
+				res.panicked = true
+				res.panicValue = r
+				res.stackTrace = asf.stackTrace()
+			}
+			done <- res
+		}()
+		res.result = call()
+	}()
+
+	timer := time.NewTimer(asf.timeout)
+	defer timer.Stop()
+	select {
+	case res := <-done:
+		return res, nil
+	case <-timer.C:
+		return callResult{}, fmt.Errorf("%w: %s did not return within %s",
+			ErrLimitExceeded, asf.describe(), asf.timeout)
+	}
+}
+
+// checkResultSize returns an error if the result exceeds the configured maximum size.
+func (asf *autoScalarFunc) checkResultSize(result any) error {
+	if asf.maxResultBytes <= 0 {
+		return nil
+	}
+	if size := resultSize(reflect.ValueOf(result)); size > asf.maxResultBytes {
+		return fmt.Errorf("%w: %s returned %d bytes, more than the maximum of %d",
+			ErrLimitExceeded, asf.describe(), size, asf.maxResultBytes)
+	}
+	return nil
+}
+
+// limitExceeded applies the LimitPolicy to an error wrapping ErrLimitExceeded.
+func (asf *autoScalarFunc) limitExceeded(err error) (any, error) {
+	if asf.limitPolicy == LimitReturnNull {
+		return nil, nil
+	}
+	return nil, err
+}
+
+// resultSize approximates the number of bytes of variable-length data held by v.
+// Strings and []byte count their length; slices, arrays, maps, structs and pointers count their contents.
+// Fixed-size values such as numbers, booleans and time.Time count as zero.
+func resultSize(v reflect.Value) int {
+	switch v.Kind() {
+	case reflect.String:
+		return v.Len()
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Len()
+		}
+		fallthrough
+	case reflect.Array:
+		size := 0
+		for i := 0; i < v.Len(); i++ {
+			size += resultSize(v.Index(i))
+		}
+		return size
+	case reflect.Map:
+		size := 0
+		iter := v.MapRange()
+		for iter.Next() {
+			size += resultSize(iter.Key()) + resultSize(iter.Value())
+		}
+		return size
+	case reflect.Struct:
+		size := 0
+		for i := 0; i < v.NumField(); i++ {
+			size += resultSize(v.Field(i))
+		}
+		return size
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return resultSize(v.Elem())
+	default:
+		return 0
+	}
+}
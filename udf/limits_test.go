@@ -0,0 +1,154 @@
+package udf
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+func TestExecutionLimits(t *testing.T) {
+	sleepy := func(ms int32) string {
+		time.Sleep(time.Duration(ms) * time.Millisecond)
+		return "done"
+	}
+	repeat := func(s string, n int32) string { return strings.Repeat(s, int(n)) }
+	repeatMap := func(n int32) map[string]string {
+		return map[string]string{"k": strings.Repeat("v", int(n))}
+	}
+	budgetPanic := func() int32 {
+		panic(fmt.Errorf("%w: step budget of 10 exhausted", ErrLimitExceeded))
+	}
+	timedPanic := func() int32 { panic("boom inside timed call") }
+
+	tests := []struct {
+		name          string
+		udfName       string
+		goFunc        any
+		options       []func(*udfOption)
+		query         string
+		expectedValue any
+		errorContains string
+	}{
+		{
+			name: "timeout not reached", udfName: "sleepy_fast_udf", goFunc: sleepy,
+			options:       []func(*udfOption){WithTimeout(time.Second)},
+			query:         "SELECT sleepy_fast_udf(1)",
+			expectedValue: "done",
+		},
+		{
+			name: "timeout exceeded", udfName: "sleepy_slow_udf", goFunc: sleepy,
+			options:       []func(*udfOption){WithTimeout(10 * time.Millisecond)},
+			query:         "SELECT sleepy_slow_udf(500)",
+			errorContains: "UDF execution limit exceeded: UDF (func type func(int32) string) did not return within 10ms",
+		},
+		{
+			name: "timeout exceeded returns NULL", udfName: "sleepy_null_udf", goFunc: sleepy,
+			options:       []func(*udfOption){WithTimeout(10 * time.Millisecond), WithLimitPolicy(LimitReturnNull)},
+			query:         "SELECT sleepy_null_udf(500)",
+			expectedValue: nil,
+		},
+		{
+			name: "panic inside timed call", udfName: "timed_panic_udf", goFunc: timedPanic,
+			options:       []func(*udfOption){WithTimeout(time.Second)},
+			query:         "SELECT timed_panic_udf()",
+			errorContains: "panic in UDF (func type func() int32): boom inside timed call",
+		},
+		{
+			name: "result within size limit", udfName: "repeat_ok_udf", goFunc: repeat,
+			options:       []func(*udfOption){WithMaxResultBytes(8)},
+			query:         "SELECT repeat_ok_udf('ab', 4)",
+			expectedValue: "abababab",
+		},
+		{
+			name: "result over size limit", udfName: "repeat_big_udf", goFunc: repeat,
+			options:       []func(*udfOption){WithMaxResultBytes(8)},
+			query:         "SELECT repeat_big_udf('ab', 5)",
+			errorContains: "returned 10 bytes, more than the maximum of 8",
+		},
+		{
+			name: "map result over size limit returns NULL", udfName: "repeat_map_udf", goFunc: repeatMap,
+			options:       []func(*udfOption){WithMaxResultBytes(8), WithLimitPolicy(LimitReturnNull)},
+			query:         "SELECT repeat_map_udf(100)",
+			expectedValue: nil,
+		},
+		{
+			name: "limit panic from user function", udfName: "budget_panic_udf", goFunc: budgetPanic,
+			options:       nil,
+			query:         "SELECT budget_panic_udf()",
+			errorContains: "UDF execution limit exceeded: step budget of 10 exhausted",
+		},
+		{
+			name: "limit panic from user function returns NULL", udfName: "budget_null_udf", goFunc: budgetPanic,
+			options:       []func(*udfOption){WithLimitPolicy(LimitReturnNull)},
+			query:         "SELECT budget_null_udf()",
+			expectedValue: nil,
+		},
+	}
+
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("Failed to open DuckDB for test suite: %v", err)
+	}
+	defer db.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := db.Conn(context.Background())
+			if err != nil {
+				t.Fatalf("Failed to get DB connection: %v", err)
+			}
+			defer conn.Close()
+
+			udfImpl, err := BuildScalarUDF(tt.goFunc, tt.options...)
+			if err != nil {
+				t.Fatalf("Failed to build UDF '%s': %v", tt.udfName, err)
+			}
+			if err := duckdb.RegisterScalarUDF(conn, tt.udfName, udfImpl); err != nil {
+				t.Fatalf("Failed to register UDF '%s': %v", tt.udfName, err)
+			}
+
+			if tt.errorContains != "" {
+				expectQueryErrorOnConn(t, conn, tt.errorContains, tt.query)
+				return
+			}
+			result := querySingleValueOnConn(t, conn, tt.query)
+			assertEqual(t, tt.expectedValue, result, "Expected %v but got %v", tt.expectedValue, result)
+		})
+	}
+}
+
+func TestResultSize(t *testing.T) {
+	type inner struct {
+		Name string
+		Data []byte
+		N    int64
+	}
+	name := "pointer"
+	tests := []struct {
+		name     string
+		value    any
+		expected int
+	}{
+		{"string", "hello", 5},
+		{"bytes", []byte{1, 2, 3}, 3},
+		{"int", int64(12345), 0},
+		{"time", time.Now(), 0},
+		{"struct", inner{Name: "ab", Data: []byte{1}, N: 7}, 3},
+		{"map", map[string]string{"ab": "cde"}, 5},
+		{"slice of strings", []string{"a", "bc"}, 3},
+		{"pointer to string", &name, 7},
+		{"nil pointer", (*inner)(nil), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := resultSize(reflect.ValueOf(tt.value))
+			assertEqual(t, tt.expected, size, "Expected size %d but got %d", tt.expected, size)
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package udf
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"runtime"
+	"runtime/pprof"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+// StatsFunctionName is the SQL name of the table function registered by RegisterStatsFunction.
+const StatsFunctionName = "duckgo_udf_stats"
+
+// Observer receives an event for every row a UDF processes. Exactly one method is called per row:
+// OnPanic if the user function panicked, OnError if the row failed otherwise, e.g. because an argument
+// could not be converted or an execution limit was exceeded, and OnCall if it succeeded.
+// The duration covers argument conversion, the call and result conversion.
+//
+// Name is the SQL name set with WithName, or the Go function name if none was set.
+// Observers are called on the goroutines DuckDB executes the query with and must be safe for concurrent use.
+type Observer interface {
+	OnCall(name string, d time.Duration)
+	OnError(name string, d time.Duration, err error)
+	OnPanic(name string, d time.Duration, value any)
+}
+
+// WithObserver reports every row the UDF processes to o. It may be given several times to notify several observers.
+func WithObserver(o Observer) func(*udfOption) {
+	return func(opt *udfOption) {
+		opt.observers = append(opt.observers, o)
+	}
+}
+
+// WithProfilerLabels tags the goroutine executing the UDF with the pprof label udf=<name>, added to the labels
+// of the context of the query, e.g. set with pprof.Do, so that CPU profiles can be broken down by function.
+// Name is determined like for Observer. The goroutine gets its own labels back after each call, as DuckDB may
+// run the UDF on the goroutine that called QueryContext.
+func WithProfilerLabels() func(*udfOption) {
+	return func(opt *udfOption) {
+		opt.profilerLabels = true
+	}
+}
+
+// metricName returns the name the UDF is reported under to observers and the profiler.
+func (asf *autoScalarFunc) metricName() string {
+	if asf.name != "" {
+		return asf.name
+	}
+	if fn := runtime.FuncForPC(asf.userFunc.Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return asf.userFunc.Type().String()
+}
+
+// observe reports the outcome of one row to the observers. panicValue is only used if panicked is set.
+func (asf *autoScalarFunc) observe(start time.Time, err error, panicked bool, panicValue any) {
+	d := time.Since(start)
+	for _, o := range asf.observers {
+		switch {
+		case panicked:
+			o.OnPanic(asf.observedName, d, panicValue)
+		case err != nil:
+			o.OnError(asf.observedName, d, err)
+		default:
+			o.OnCall(asf.observedName, d)
+		}
+	}
+}
+
+// setProfilerLabels tags the current goroutine with the pprof labels of ctx and returns a function restoring
+// the labels it had before.
+func setProfilerLabels(ctx context.Context) func() {
+	saved := getProfLabel()
+	pprof.SetGoroutineLabels(ctx)
+	return func() { setProfLabel(saved) }
+}
+
+// getProfLabel and setProfLabel get and set the pprof labels of the current goroutine. runtime/pprof only
+// sets them from a context, so the labels of a goroutine cannot be restored otherwise; the runtime keeps
+// these functions for packages linking to them.
+//
+//go:linkname getProfLabel runtime/pprof.runtime_getProfLabel
+func getProfLabel() unsafe.Pointer
+
+//go:linkname setProfLabel runtime/pprof.runtime_setProfLabel
+func setProfLabel(labels unsafe.Pointer)
+
+// LatencyBuckets are the upper bounds of the latency histogram buckets of a Collector.
+// Durations above the last bound are counted in an additional overflow bucket.
+var LatencyBuckets = []time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// Collector is an Observer that counts calls, errors and panics per UDF and records their latencies
+// in a histogram. Pass it to WithObserver for every UDF to monitor, and read the counters with Stats
+// or with SQL through RegisterStatsFunction.
+type Collector struct {
+	funcs sync.Map // UDF name to *funcCounters
+}
+
+// funcCounters are the counters of one UDF, updated atomically.
+type funcCounters struct {
+	calls, errors, panics atomic.Int64
+	totalNanos, maxNanos  atomic.Int64
+	buckets               []atomic.Int64 // len(LatencyBuckets)+1
+}
+
+// NewCollector returns an empty Collector.
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// FuncStats are the counters of one UDF, as returned by Collector.Stats.
+type FuncStats struct {
+	Name          string
+	Calls         int64 // All rows, including failed ones
+	Errors        int64 // Rows that failed without a panic
+	Panics        int64 // Rows whose call panicked
+	TotalDuration time.Duration
+	MaxDuration   time.Duration
+	Buckets       []int64 // Rows per LatencyBuckets bound, followed by the overflow bucket
+}
+
+// OnCall implements Observer.
+func (c *Collector) OnCall(name string, d time.Duration) {
+	c.counters(name).record(d)
+}
+
+// OnError implements Observer.
+func (c *Collector) OnError(name string, d time.Duration, err error) {
+	fc := c.counters(name)
+	fc.errors.Add(1)
+	fc.record(d)
+}
+
+// OnPanic implements Observer.
+func (c *Collector) OnPanic(name string, d time.Duration, value any) {
+	fc := c.counters(name)
+	fc.panics.Add(1)
+	fc.record(d)
+}
+
+// counters returns the counters of the UDF name, creating them on first use.
+func (c *Collector) counters(name string) *funcCounters {
+	if fc, ok := c.funcs.Load(name); ok {
+		return fc.(*funcCounters)
+	}
+	fc, _ := c.funcs.LoadOrStore(name, &funcCounters{buckets: make([]atomic.Int64, len(LatencyBuckets)+1)})
+	return fc.(*funcCounters)
+}
+
+// record counts a row that took d.
+func (fc *funcCounters) record(d time.Duration) {
+	fc.calls.Add(1)
+	fc.totalNanos.Add(int64(d))
+	for {
+		old := fc.maxNanos.Load()
+		if int64(d) <= old || fc.maxNanos.CompareAndSwap(old, int64(d)) {
+			break
+		}
+	}
+	i, _ := slices.BinarySearch(LatencyBuckets, d)
+	fc.buckets[i].Add(1)
+}
+
+// Stats returns the counters of all observed UDFs, sorted by name.
+// Counters of rows that finish while Stats runs may be partially included.
+func (c *Collector) Stats() []FuncStats {
+	var stats []FuncStats
+	c.funcs.Range(func(key, value any) bool {
+		fc := value.(*funcCounters)
+		s := FuncStats{
+			Name:          key.(string),
+			Calls:         fc.calls.Load(),
+			Errors:        fc.errors.Load(),
+			Panics:        fc.panics.Load(),
+			TotalDuration: time.Duration(fc.totalNanos.Load()),
+			MaxDuration:   time.Duration(fc.maxNanos.Load()),
+			Buckets:       make([]int64, len(fc.buckets)),
+		}
+		for i := range fc.buckets {
+			s.Buckets[i] = fc.buckets[i].Load()
+		}
+		stats = append(stats, s)
+		return true
+	})
+	slices.SortFunc(stats, func(a, b FuncStats) int { return strings.Compare(a.Name, b.Name) })
+	return stats
+}
+
+// Reset discards all counters.
+func (c *Collector) Reset() {
+	c.funcs.Clear()
+}
+
+// AvgDuration returns the mean duration of a row, or 0 if there were no calls.
+func (s FuncStats) AvgDuration() time.Duration {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.TotalDuration / time.Duration(s.Calls)
+}
+
+// Quantile estimates the q-th quantile (0 <= q <= 1) of the row durations as the upper bound of the histogram
+// bucket containing it. Quantiles in the overflow bucket return MaxDuration. It returns 0 if there were no calls.
+func (s FuncStats) Quantile(q float64) time.Duration {
+	var total int64
+	for _, n := range s.Buckets {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(total)))
+	var seen int64
+	for i, n := range s.Buckets {
+		seen += n
+		if seen >= rank && n > 0 {
+			if i < len(LatencyBuckets) {
+				return min(LatencyBuckets[i], s.MaxDuration)
+			}
+			break
+		}
+	}
+	return s.MaxDuration
+}
+
+// RegisterStatsFunction registers the table function duckgo_udf_stats() on conn, which returns
+// one row per UDF observed by c:
+//
+//	SELECT name, calls, errors, panics, avg_ms, p99_ms FROM duckgo_udf_stats() ORDER BY total_ms DESC
+//
+// Durations are returned in milliseconds; the quantiles are estimated as described at FuncStats.Quantile.
+func RegisterStatsFunction(conn *sql.Conn, c *Collector) error {
+	return duckdb.RegisterTableUDF(conn, StatsFunctionName, duckdb.RowTableFunction{
+		BindArguments: func(named map[string]any, args ...any) (duckdb.RowTableSource, error) {
+			return newStatsSource(c.Stats())
+		},
+	})
+}
+
+// statsSource returns a snapshot of the counters of a Collector as rows.
+type statsSource struct {
+	columns []duckdb.ColumnInfo
+	stats   []FuncStats
+	next    int
+}
+
+func newStatsSource(stats []FuncStats) (*statsSource, error) {
+	varchar, err := duckdb.NewTypeInfo(duckdb.TYPE_VARCHAR)
+	if err != nil {
+		return nil, err
+	}
+	bigint, err := duckdb.NewTypeInfo(duckdb.TYPE_BIGINT)
+	if err != nil {
+		return nil, err
+	}
+	double, err := duckdb.NewTypeInfo(duckdb.TYPE_DOUBLE)
+	if err != nil {
+		return nil, err
+	}
+	columns := []duckdb.ColumnInfo{{Name: "name", T: varchar}}
+	for _, name := range []string{"calls", "errors", "panics"} {
+		columns = append(columns, duckdb.ColumnInfo{Name: name, T: bigint})
+	}
+	for _, name := range []string{"total_ms", "avg_ms", "max_ms", "p50_ms", "p90_ms", "p99_ms"} {
+		columns = append(columns, duckdb.ColumnInfo{Name: name, T: double})
+	}
+	return &statsSource{columns: columns, stats: stats}, nil
+}
+
+func (s *statsSource) ColumnInfos() []duckdb.ColumnInfo {
+	return s.columns
+}
+
+func (s *statsSource) Cardinality() *duckdb.CardinalityInfo {
+	return &duckdb.CardinalityInfo{Cardinality: uint(len(s.stats)), Exact: true}
+}
+
+func (s *statsSource) Init() {}
+
+func (s *statsSource) FillRow(row duckdb.Row) (bool, error) {
+	if s.next >= len(s.stats) {
+		return false, nil
+	}
+	st := s.stats[s.next]
+	s.next++
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	values := []any{st.Name, st.Calls, st.Errors, st.Panics,
+		ms(st.TotalDuration), ms(st.AvgDuration()), ms(st.MaxDuration),
+		ms(st.Quantile(0.5)), ms(st.Quantile(0.9)), ms(st.Quantile(0.99))}
+	for i, v := range values {
+		if err := duckdb.SetRowValue(row, i, v); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,183 @@
+package udf
+
+import (
+	"context"
+	"database/sql"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+func TestCollector(t *testing.T) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get DB connection: %v", err)
+	}
+	defer conn.Close()
+
+	collector := NewCollector()
+	udfs := map[string]any{
+		"obs_repeat": strings.Repeat,
+		"obs_div":    func(a, b int32) int32 { return a / b },
+	}
+	for name, fn := range udfs {
+		sf, err := BuildScalarUDF(fn, WithName(name), WithObserver(collector), WithMaxResultBytes(5), WithProfilerLabels())
+		if err != nil {
+			t.Fatalf("BuildScalarUDF(%s) failed: %v", name, err)
+		}
+		if err := duckdb.RegisterScalarUDF(conn, name, sf); err != nil {
+			t.Fatalf("Failed to register %s: %v", name, err)
+		}
+	}
+	if err := RegisterStatsFunction(conn, collector); err != nil {
+		t.Fatalf("RegisterStatsFunction failed: %v", err)
+	}
+
+	querySingleValueOnConn(t, conn, "SELECT sum(length(obs_repeat('ab', i::INTEGER))) FROM range(3) t(i)")
+	// Arguments come from a table, as DuckDB evaluates failing constant expressions again at execution
+	expectQueryErrorOnConn(t, conn, "more than the maximum of 5", "SELECT obs_repeat(s, n) FROM (VALUES ('ab', 3)) t(s, n)")
+	querySingleValueOnConn(t, conn, "SELECT obs_div(a, b) FROM (VALUES (6, 3)) t(a, b)")
+	expectQueryErrorOnConn(t, conn, "integer divide by zero", "SELECT obs_div(a, b) FROM (VALUES (1, 0)) t(a, b)")
+
+	stats := collector.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("expected stats of 2 functions, got %+v", stats)
+	}
+	want := []struct {
+		name                  string
+		calls, errors, panics int64
+	}{
+		{"obs_div", 2, 0, 1},
+		{"obs_repeat", 4, 1, 0},
+	}
+	for i, w := range want {
+		s := stats[i]
+		if s.Name != w.name || s.Calls != w.calls || s.Errors != w.errors || s.Panics != w.panics {
+			t.Errorf("stats[%d] = %+v, want %+v", i, s, w)
+		}
+		var inBuckets int64
+		for _, n := range s.Buckets {
+			inBuckets += n
+		}
+		if inBuckets != s.Calls || s.MaxDuration <= 0 || s.TotalDuration < s.MaxDuration {
+			t.Errorf("inconsistent durations for %s: %+v", s.Name, s)
+		}
+	}
+
+	rows, err := conn.QueryContext(context.Background(),
+		"SELECT name, calls, errors, panics, p99_ms <= max_ms FROM duckgo_udf_stats() ORDER BY name")
+	if err != nil {
+		t.Fatalf("querying %s failed: %v", StatsFunctionName, err)
+	}
+	defer rows.Close()
+	for i := 0; rows.Next(); i++ {
+		var name string
+		var calls, errors, panics int64
+		var quantileBelowMax bool
+		if err := rows.Scan(&name, &calls, &errors, &panics, &quantileBelowMax); err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		if name != want[i].name || calls != want[i].calls || errors != want[i].errors || panics != want[i].panics || !quantileBelowMax {
+			t.Errorf("row %d = %s %d %d %d %v, want %+v", i, name, calls, errors, panics, quantileBelowMax, want[i])
+		}
+	}
+
+	collector.Reset()
+	if stats := collector.Stats(); len(stats) != 0 {
+		t.Errorf("expected no stats after Reset, got %+v", stats)
+	}
+}
+
+func TestFuncStatsQuantile(t *testing.T) {
+	buckets := make([]int64, len(LatencyBuckets)+1)
+	buckets[0] = 90                  // <= 1µs
+	buckets[3] = 9                   // <= 1ms
+	buckets[len(LatencyBuckets)] = 1 // > 10s
+	s := FuncStats{Calls: 100, MaxDuration: time.Minute, Buckets: buckets}
+	testCases := []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0, time.Microsecond},
+		{0.5, time.Microsecond},
+		{0.9, time.Microsecond},
+		{0.95, time.Millisecond},
+		{0.99, time.Millisecond},
+		{1, time.Minute},
+	}
+	for _, tc := range testCases {
+		if got := s.Quantile(tc.q); got != tc.want {
+			t.Errorf("Quantile(%v) = %v, want %v", tc.q, got, tc.want)
+		}
+	}
+	if got := (FuncStats{}).Quantile(0.5); got != 0 {
+		t.Errorf("Quantile of empty stats = %v, want 0", got)
+	}
+}
+
+func TestWithProfilerLabels(t *testing.T) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get DB connection: %v", err)
+	}
+	defer conn.Close()
+	// A single thread runs the query on the goroutine calling QueryContext
+	if _, err := conn.ExecContext(context.Background(), "SET threads = 1"); err != nil {
+		t.Fatalf("SET threads failed: %v", err)
+	}
+
+	var seen []map[string]string
+	labelled := func(s string) string {
+		seen = append(seen, goroutineLabels())
+		return s
+	}
+	if err := Register(conn, "labelled", labelled, WithProfilerLabels()); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	pprof.Do(context.Background(), pprof.Labels("request", "r1"), func(ctx context.Context) {
+		before := getProfLabel()
+		var n int64
+		if err := conn.QueryRowContext(ctx, "SELECT count(labelled('x' || i::VARCHAR)) FROM range(3) t(i)").Scan(&n); err != nil {
+			t.Fatalf("query failed: %v", err)
+		}
+		if getProfLabel() != before {
+			t.Errorf("labels of the caller changed to %v", goroutineLabels())
+		}
+	})
+	if len(seen) == 0 {
+		t.Fatal("UDF not called")
+	}
+	for _, labels := range seen {
+		if labels["request"] != "r1" || labels["udf"] != "labelled" {
+			t.Errorf("UDF ran with labels %v, want request=r1 and udf=labelled", labels)
+		}
+	}
+}
+
+// goroutineLabels returns the pprof labels of the current goroutine. runtime/pprof stores them as a LabelSet,
+// the only field of its label map.
+func goroutineLabels() map[string]string {
+	labels := make(map[string]string)
+	if p := getProfLabel(); p != nil {
+		ctx := pprof.WithLabels(context.Background(), *(*pprof.LabelSet)(p))
+		pprof.ForLabels(ctx, func(key, value string) bool {
+			labels[key] = value
+			return true
+		})
+	}
+	return labels
+}
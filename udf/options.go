@@ -3,16 +3,37 @@ package udf
 import (
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/duckdb/duckdb-go/v2"
+	"github.com/ma6174/duckgo/internal/convert"
 )
 
 // udfOption contains optional parameters for BuildScalarUDF.
 type udfOption struct {
 	volatile            bool
 	specialNullHandling bool
+	timeout             time.Duration
+	maxResultBytes      int
+	limitPolicy         LimitPolicy
+	name                string
+	observers           []Observer
+	profilerLabels      bool
+	cache               Cache
+	maxConcurrency      int
+	panicPolicy         PanicPolicy
+	argRedactor         ArgRedactor
+	stackDepth          int
+	defaults            []any
+	chunkDedup          bool
+	prepare             map[int]any
+	state               *stateSpec
 }
 
+// Option configures a UDF built by BuildScalarUDF.
+// It allows other packages to collect and forward options, e.g. []udf.Option.
+type Option = func(*udfOption)
+
 // WithVolatile sets whether the UDF is a volatile function.
 // Volatile functions may return different results for the same inputs (e.g., random()).
 // If a function always returns the same result for the same inputs, this option should not be set or set to false.
@@ -31,6 +52,44 @@ func WithSpecialNullHandling(s bool) func(*udfOption) {
 	}
 }
 
+// WithTimeout limits the wall-clock time of a single call of the user function.
+// A call that does not return within d exceeds the limit and is handled according to the LimitPolicy.
+// Go cannot stop a running goroutine, so the abandoned call keeps running in the background until it returns;
+// interpreted script functions should additionally use a step budget to actually stop runaway code.
+// A zero or negative d disables the timeout (default).
+func WithTimeout(d time.Duration) func(*udfOption) {
+	return func(o *udfOption) {
+		o.timeout = d
+	}
+}
+
+// WithMaxResultBytes limits the size of the value returned by a single call of the user function.
+// The size is the length of strings and []byte values, summed over the elements of slices, maps and struct fields.
+// A result larger than n bytes exceeds the limit and is handled according to the LimitPolicy.
+// A zero or negative n disables the check (default).
+func WithMaxResultBytes(n int) func(*udfOption) {
+	return func(o *udfOption) {
+		o.maxResultBytes = n
+	}
+}
+
+// WithLimitPolicy sets what happens to a row whose call exceeds an execution limit.
+// The default, LimitFail, fails the query with an error wrapping ErrLimitExceeded.
+func WithLimitPolicy(p LimitPolicy) func(*udfOption) {
+	return func(o *udfOption) {
+		o.limitPolicy = p
+	}
+}
+
+// WithName sets the SQL name the UDF is registered under.
+// DuckDB does not tell a UDF its name, so it is only used to name the function in error messages
+// and must match the name passed to duckdb.RegisterScalarUDF.
+func WithName(name string) func(*udfOption) {
+	return func(o *udfOption) {
+		o.name = name
+	}
+}
+
 // BuildScalarUDF builds a DuckDB scalar user-defined function (UDF) from a Go function.
 //
 // The fn parameter must be a Go function that meets the following requirements:
@@ -44,11 +103,19 @@ func WithSpecialNullHandling(s bool) func(*udfOption) {
 //   - Pointers to structs, maps, or time.Time (e.g., *MyStruct, *map[string]int, *time.Time), which will be automatically dereferenced during type mapping.
 //
 // Options such as WithVolatile(true) or WithSpecialNullHandling(true) can be passed through the opts parameter to configure UDF behavior.
-// By default, UDFs are non-volatile and do not use special NULL handling.
+// Execution limits can be set with WithTimeout, WithMaxResultBytes and WithLimitPolicy.
+// Calls can be monitored with WithObserver and WithProfilerLabels, and results reused with WithCache,
+// or within each chunk of rows with WithChunkDedup.
+// WithMaxConcurrency and WithSerialized bound how many calls run at the same time.
+// Panics fail the query by default; see WithPanicPolicy, WithArgRedactor and WithStackDepth.
+// WithDefaults makes trailing arguments optional; build such functions with BuildScalarUDFSet.
+// WithPrepare converts constant arguments once per query, for parameters of any type.
+// WithState passes a state of a per-query pool as first parameter, which is not an SQL argument.
+// By default, UDFs are non-volatile, do not use special NULL handling and run without limits.
 //
 // Returns a UDF that implements the duckdb.ScalarFunc interface, which can be registered to DuckDB via RegisterScalarUDF.
 //
-// Returns an error if fn is not a function, returns multiple values, or uses unsupported types.
+// Returns a *BuildError if fn is not a function, returns multiple values, or uses unsupported types.
 func BuildScalarUDF(fn any, opts ...func(*udfOption)) (duckdb.ScalarFunc, error) {
 	options := &udfOption{
 		volatile:            false, // Default value
@@ -62,16 +129,32 @@ func BuildScalarUDF(fn any, opts ...func(*udfOption)) (duckdb.ScalarFunc, error)
 	funcType := funcVal.Type()
 
 	if funcType.Kind() != reflect.Func {
-		return nil, fmt.Errorf("BuildScalarUDF: input 'function' (type %s) is not a function, but %s", funcType.String(), funcType.Kind())
+		return nil, &BuildError{GoType: funcType, FuncType: funcType,
+			Reason: fmt.Errorf("input 'function' (type %s) is not a function, but %s", funcType.String(), funcType.Kind())}
 	}
 	if funcType.NumOut() != 1 {
-		return nil, fmt.Errorf("BuildScalarUDF: function (type %s) must return exactly one value, but returns %d", funcType.String(), funcType.NumOut())
+		return nil, &BuildError{GoType: funcType, FuncType: funcType,
+			Reason: fmt.Errorf("function (type %s) must return exactly one value, but returns %d", funcType.String(), funcType.NumOut())}
+	}
+
+	if options.cache != nil && options.volatile {
+		return nil, &BuildError{GoType: funcType, FuncType: funcType, Reason: ErrVolatileCache}
+	}
+	if options.chunkDedup && options.volatile {
+		return nil, &BuildError{GoType: funcType, FuncType: funcType, Reason: ErrVolatileDedup}
 	}
 
-	numTotalGoArgs := funcType.NumIn()
+	firstGoArg := 0 // Index of the first parameter taking an SQL argument
+	if options.state != nil {
+		if err := options.state.checkStateParam(funcType); err != nil {
+			return nil, err
+		}
+		firstGoArg = 1
+	}
+	numTotalGoArgs := funcType.NumIn() - firstGoArg
 	goArgTypes := make([]reflect.Type, numTotalGoArgs)
 	for i := range numTotalGoArgs {
-		goArgTypes[i] = funcType.In(i)
+		goArgTypes[i] = funcType.In(firstGoArg + i)
 	}
 
 	isGoFuncVariadic := funcType.IsVariadic()
@@ -81,35 +164,43 @@ func BuildScalarUDF(fn any, opts ...func(*udfOption)) (duckdb.ScalarFunc, error)
 	numFixedArgs := numTotalGoArgs
 	if isGoFuncVariadic {
 		if numTotalGoArgs == 0 { // Should not happen if IsVariadic is true, but defensive
-			return nil, fmt.Errorf("BuildScalarUDF: variadic function (type %s) has no arguments", funcType.String())
+			return nil, &BuildError{GoType: funcType, FuncType: funcType,
+				Reason: fmt.Errorf("variadic function (type %s) has no arguments", funcType.String())}
 		}
 		numFixedArgs--                                // Last Go arg is the variadic slice
 		variadicSliceType := goArgTypes[numFixedArgs] // e.g. []int
 		variadicElemType := variadicSliceType.Elem()  // e.g. int
 		var err error
-		duckDBVariadicElemTypeInfo, err = goTypeToDuckDBTypeInfo(variadicElemType)
+		duckDBVariadicElemTypeInfo, err = convert.TypeInfoOf(variadicElemType)
 		if err != nil {
-			return nil, fmt.Errorf("BuildScalarUDF: error converting Go variadic element type for UDF (Go type %s, func type %s): %w", variadicElemType.String(), funcType.String(), err)
+			return nil, &BuildError{Param: "variadic", GoType: variadicElemType, FuncType: funcType, Reason: err}
 		}
 	}
 
+	prepared, err := buildPreparers(funcType, goArgTypes[:numFixedArgs], options.prepare)
+	if err != nil {
+		return nil, err
+	}
 	duckDBInputTypeInfos = make([]duckdb.TypeInfo, numFixedArgs)
 	for i := 0; i < numFixedArgs; i++ {
 		goArgType := goArgTypes[i]
-		duckDBTypeInfo, err := goTypeToDuckDBTypeInfo(goArgType)
+		if p, ok := prepared[i]; ok {
+			goArgType = p.inputType // DuckDB passes the input of the prepare function
+		}
+		duckDBTypeInfo, err := convert.TypeInfoOf(goArgType)
 		if err != nil {
-			return nil, fmt.Errorf("BuildScalarUDF: error converting Go type for fixed argument %d of UDF (Go type %s, func type %s): %w", i, goArgType.String(), funcType.String(), err)
+			return nil, &BuildError{Param: fmt.Sprintf("argument %d", i), GoType: goArgType, FuncType: funcType, Reason: err}
 		}
 		duckDBInputTypeInfos[i] = duckDBTypeInfo
 	}
 
 	goReturnType := funcType.Out(0)
-	duckDBResultTypeInfo, err := goTypeToDuckDBTypeInfo(goReturnType)
+	duckDBResultTypeInfo, err := convert.TypeInfoOf(goReturnType)
 	if err != nil {
-		return nil, fmt.Errorf("BuildScalarUDF: error converting Go return type for UDF (Go type %s, func type %s): %w", goReturnType.String(), funcType.String(), err)
+		return nil, &BuildError{Param: "result", GoType: goReturnType, FuncType: funcType, Reason: err}
 	}
 
-	return &autoScalarFunc{
+	asf := &autoScalarFunc{
 		userFunc:               funcVal,
 		goArgTypes:             goArgTypes, // Store all Go arg types, including variadic slice type
 		goReturnType:           goReturnType,
@@ -117,7 +208,32 @@ func BuildScalarUDF(fn any, opts ...func(*udfOption)) (duckdb.ScalarFunc, error)
 		duckDBResultTypeInfo:   duckDBResultTypeInfo,
 		specialNullHandling:    options.specialNullHandling,
 		volatile:               options.volatile,
+		timeout:                options.timeout,
+		maxResultBytes:         options.maxResultBytes,
+		limitPolicy:            options.limitPolicy,
+		name:                   options.name,
 		isVariadic:             isGoFuncVariadic,
 		duckDBVariadicTypeInfo: duckDBVariadicElemTypeInfo, // TypeInfo for the *element* of variadic part
-	}, nil
+		observers:              options.observers,
+		cache:                  options.cache,
+		panicPolicy:            options.panicPolicy,
+		argRedactor:            options.argRedactor,
+		stackDepth:             options.stackDepth,
+		chunkDedup:             options.chunkDedup,
+		state:                  options.state,
+	}
+	if len(prepared) > 0 {
+		asf.prepared = prepared
+	}
+	if options.maxConcurrency > 0 {
+		asf.slots = make(chan struct{}, options.maxConcurrency)
+	}
+	if len(options.defaults) > 0 {
+		if err := asf.convertDefaults(options.defaults); err != nil {
+			return nil, err
+		}
+	}
+	asf.observedName = asf.metricName()
+	asf.profilerLabels = options.profilerLabels
+	return asf, nil
 }
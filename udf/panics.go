@@ -0,0 +1,166 @@
+package udf
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// PanicPolicy controls what happens to a row whose user function panics.
+// Panics carrying an error that wraps ErrLimitExceeded are handled by the LimitPolicy instead.
+type PanicPolicy int
+
+const (
+	// PanicError fails the query with an error describing the panic (default).
+	PanicError PanicPolicy = iota
+	// PanicReturnNull returns SQL NULL for the row and continues with the next one.
+	// Observers are still notified of the panic.
+	PanicReturnNull
+	// PanicRethrow panics again with the original value. DuckDB calls UDFs from C and cannot recover Go panics,
+	// so this terminates the process with the stack trace of the panic. Use it while debugging,
+	// or if crashing is preferable to continuing after an unexpected state.
+	PanicRethrow
+)
+
+// defaultStackDepth is the number of stack frames included in panic errors unless set with WithStackDepth.
+const defaultStackDepth = 32
+
+// ArgRedactor formats the argument at index for panic error messages. Value is the value received from DuckDB,
+// nil for SQL NULL.
+type ArgRedactor func(index int, value driver.Value) string
+
+// TypesOnly is an ArgRedactor that only reports the Go type of each argument, never its value.
+func TypesOnly(index int, value driver.Value) string {
+	if value == nil {
+		return "NULL"
+	}
+	return fmt.Sprintf("(type %T)", value)
+}
+
+// formatArg is the default ArgRedactor, reporting the value and the Go type of each argument.
+func formatArg(index int, value driver.Value) string {
+	if value == nil {
+		return "NULL"
+	}
+	return fmt.Sprintf("%v (type %T)", value, value)
+}
+
+// WithPanicPolicy sets what happens to a row whose user function panics. The default is PanicError.
+func WithPanicPolicy(p PanicPolicy) func(*udfOption) {
+	return func(o *udfOption) {
+		o.panicPolicy = p
+	}
+}
+
+// WithArgRedactor sets how arguments are shown in panic error messages. By default every argument is shown
+// with its value and type; pass TypesOnly, or a function masking sensitive values, to keep personal data
+// out of error messages and logs. With a redactor, the panic value is only shown by its type, because
+// messages such as panic("bad email " + email) contain the arguments; error values can still be
+// inspected with errors.Is and errors.As.
+func WithArgRedactor(r ArgRedactor) func(*udfOption) {
+	return func(o *udfOption) {
+		o.argRedactor = r
+	}
+}
+
+// WithStackDepth sets the maximum number of stack frames included in panic error messages,
+// starting at the function that panicked. The default is 32; a negative n omits the stack trace.
+func WithStackDepth(n int) func(*udfOption) {
+	return func(o *udfOption) {
+		o.stackDepth = n
+	}
+}
+
+// stackTrace returns the stack of the panicking goroutine, from the frame that panicked, limited to the
+// configured depth. It must be called from the deferred function recovering the panic.
+func (asf *autoScalarFunc) stackTrace() string {
+	depth := asf.stackDepth
+	if depth == 0 {
+		depth = defaultStackDepth
+	}
+	if depth < 0 || asf.panicPolicy != PanicError {
+		return "" // Not reported
+	}
+	// Room for the frames of the recovery itself, which are skipped below
+	pcs := make([]uintptr, depth+16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	var all []runtime.Frame
+	for {
+		frame, more := frames.Next()
+		all = append(all, frame)
+		if !more {
+			break
+		}
+	}
+	for i, frame := range all {
+		if frame.Function == "runtime.gopanic" {
+			all = all[i+1:]
+			break
+		}
+	}
+	var b strings.Builder
+	for i, frame := range all {
+		if i == depth {
+			b.WriteString("...\n")
+			break
+		}
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+	return b.String()
+}
+
+// panicError converts a value recovered from a panic in the user function into the result of the row,
+// according to the PanicPolicy. Panics carrying an error that wraps ErrLimitExceeded are reported as
+// limit violations instead.
+func (asf *autoScalarFunc) panicError(r any, stackTrace string, inputArgs []driver.Value) (any, error) {
+	if err, ok := r.(error); ok && errors.Is(err, ErrLimitExceeded) {
+		return asf.limitExceeded(err)
+	}
+	switch asf.panicPolicy {
+	case PanicReturnNull:
+		return nil, nil
+	case PanicRethrow:
+		panic(r)
+	}
+
+	// Provide richer error context, including function type, parameter info, and stack trace
+	redact := asf.argRedactor
+	if redact == nil {
+		redact = formatArg
+	}
+	argValues := make([]string, len(inputArgs))
+	for i, arg := range inputArgs {
+		argValues[i] = redact(i, arg)
+	}
+
+	// Keep error panic values inspectable with errors.As
+	cause, ok := r.(error)
+	switch {
+	case asf.argRedactor != nil:
+		cause = redactedPanic{r}
+	case !ok:
+		cause = fmt.Errorf("%v", r)
+	}
+	if stackTrace == "" {
+		return nil, fmt.Errorf("panic in %s: %w\nParameters: %v", asf.describe(), cause, argValues)
+	}
+	return nil, fmt.Errorf("panic in %s: %w\nParameters: %v\nStack trace:\n%s",
+		asf.describe(), cause, argValues, stackTrace)
+}
+
+// redactedPanic reports a panic value by its type only, as its message may contain redacted arguments.
+type redactedPanic struct {
+	value any
+}
+
+func (p redactedPanic) Error() string {
+	return fmt.Sprintf("panic value of type %T (redacted)", p.value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (p redactedPanic) Unwrap() error {
+	err, _ := p.value.(error)
+	return err
+}
@@ -0,0 +1,127 @@
+package udf
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// panicRecorder is an Observer counting panics.
+type panicRecorder struct{ panics int }
+
+func (r *panicRecorder) OnCall(string, time.Duration)         {}
+func (r *panicRecorder) OnError(string, time.Duration, error) {}
+func (r *panicRecorder) OnPanic(string, time.Duration, any)   { r.panics++ }
+
+// errInvalid is wrapped by panic values.
+var errInvalid = errors.New("invalid")
+
+func explode(email string) string {
+	panic("cannot handle " + email)
+}
+
+func TestPanicPolicy(t *testing.T) {
+	args := []driver.Value{"secret@example.com"}
+	run := func(t *testing.T, opts ...Option) (any, error) {
+		t.Helper()
+		sf, err := BuildScalarUDF(explode, append([]Option{WithName("explode")}, opts...)...)
+		if err != nil {
+			t.Fatalf("BuildScalarUDF failed: %v", err)
+		}
+		return sf.Executor().RowExecutor(args)
+	}
+
+	t.Run("error with values and stack", func(t *testing.T) {
+		_, err := run(t)
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		msg := err.Error()
+		for _, want := range []string{
+			"panic in UDF explode (func type func(string) string): cannot handle secret@example.com",
+			"Parameters: [secret@example.com (type string)]",
+			"Stack trace:\ngithub.com/ma6174/duckgo/udf.explode\n",
+		} {
+			if !strings.Contains(msg, want) {
+				t.Errorf("error message does not contain %q:\n%s", want, msg)
+			}
+		}
+	})
+
+	t.Run("types only", func(t *testing.T) {
+		_, err := run(t, WithArgRedactor(TypesOnly))
+		if err == nil || !strings.Contains(err.Error(), "Parameters: [(type string)]") {
+			t.Fatalf("expected only argument types, got %v", err)
+		}
+		// The panic message contains the argument too
+		if !strings.Contains(err.Error(), "explode (func type func(string) string): panic value of type string (redacted)") {
+			t.Errorf("expected only the type of the panic value, got %v", err)
+		}
+		if strings.Contains(err.Error(), "secret@example.com") {
+			t.Errorf("argument value leaked into the error: %v", err)
+		}
+
+		sf, err := BuildScalarUDF(func(s string) string { panic(fmt.Errorf("bad email %s: %w", s, errInvalid)) },
+			WithArgRedactor(TypesOnly))
+		if err != nil {
+			t.Fatalf("BuildScalarUDF failed: %v", err)
+		}
+		_, err = sf.Executor().RowExecutor(args)
+		if !errors.Is(err, errInvalid) || strings.Contains(err.Error(), "secret@example.com") {
+			t.Errorf("expected a redacted error wrapping the panic value, got %v", err)
+		}
+	})
+
+	t.Run("custom redactor", func(t *testing.T) {
+		mask := func(i int, v driver.Value) string { return strings.Repeat("*", len(v.(string))) }
+		_, err := run(t, WithArgRedactor(mask))
+		if err == nil || !strings.Contains(err.Error(), "Parameters: [******************]") {
+			t.Fatalf("expected masked argument, got %v", err)
+		}
+		if strings.Contains(err.Error(), "secret@example.com") {
+			t.Errorf("argument value leaked into the error: %v", err)
+		}
+	})
+
+	t.Run("stack depth", func(t *testing.T) {
+		_, err := run(t, WithStackDepth(2))
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		_, trace, _ := strings.Cut(err.Error(), "Stack trace:\n")
+		if lines := strings.Split(strings.TrimSuffix(trace, "\n"), "\n"); len(lines) != 5 || lines[4] != "..." {
+			t.Errorf("expected 2 frames followed by ..., got:\n%s", trace)
+		}
+
+		_, err = run(t, WithStackDepth(-1))
+		if err == nil || strings.Contains(err.Error(), "Stack trace") {
+			t.Errorf("expected no stack trace, got %v", err)
+		}
+	})
+
+	t.Run("return null", func(t *testing.T) {
+		recorder := &panicRecorder{}
+		result, err := run(t, WithPanicPolicy(PanicReturnNull), WithObserver(recorder))
+		if result != nil || err != nil {
+			t.Errorf("expected NULL, got %v, %v", result, err)
+		}
+		if recorder.panics != 1 {
+			t.Errorf("observer saw %d panics, want 1", recorder.panics)
+		}
+	})
+
+	for _, timeout := range []time.Duration{0, time.Minute} {
+		t.Run(fmt.Sprintf("rethrow with timeout %v", timeout), func(t *testing.T) {
+			defer func() {
+				if r := recover(); r != "cannot handle secret@example.com" {
+					t.Errorf("expected the original panic value, got %v", r)
+				}
+			}()
+			run(t, WithPanicPolicy(PanicRethrow), WithTimeout(timeout))
+			t.Error("expected a panic")
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package udf
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/ma6174/duckgo/internal/convert"
+)
+
+// WithPrepare turns the SQL argument at index arg into the value the function takes with prepare, which must be
+// a func(T) P or func(T) (P, error). T is the Go type of the SQL argument and P the type of the parameter,
+// which does not need to be supported by UDFs, so that expensive setup such as compiling a pattern happens once:
+//
+//	match := func(s string, re *regexp.Regexp) bool { return re.MatchString(s) }
+//	err := udf.Register(conn, "go_regex_match", match, udf.WithPrepare(1, regexp.Compile))
+//	// SELECT go_regex_match(name, '^a.*z$') FROM users
+//
+// A constant argument is prepared once per query, when DuckDB binds the function, and an error of prepare fails
+// the query before any row is executed. Arguments computed per row are prepared for every row. NULL is not
+// passed to prepare, but handled as for any other parameter. The option can be given for several arguments,
+// but not for the variadic ones.
+func WithPrepare(arg int, prepare any) func(*udfOption) {
+	return func(o *udfOption) {
+		if o.prepare == nil {
+			o.prepare = make(map[int]any)
+		}
+		o.prepare[arg] = prepare
+	}
+}
+
+// preparer turns SQL argument values into the values of a parameter.
+type preparer struct {
+	fn        reflect.Value
+	inputType reflect.Type // Go type of the SQL argument
+	hasError  bool         // Whether fn also returns an error
+}
+
+// buildPreparers checks the prepare functions given with WithPrepare for the fixed parameters of funcType.
+func buildPreparers(funcType reflect.Type, fixedArgTypes []reflect.Type, prepare map[int]any) (map[int]*preparer, error) {
+	preparers := make(map[int]*preparer, len(prepare))
+	for arg, fn := range prepare {
+		if arg < 0 || arg >= len(fixedArgTypes) {
+			return nil, &BuildError{GoType: funcType, FuncType: funcType,
+				Reason: fmt.Errorf("cannot prepare argument %d of a function with %d fixed parameters", arg, len(fixedArgTypes))}
+		}
+		paramType := fixedArgTypes[arg]
+		fv := reflect.ValueOf(fn)
+		if fn == nil || fv.Kind() != reflect.Func || fv.Type().NumIn() != 1 || fv.Type().IsVariadic() ||
+			fv.Type().NumOut() < 1 || fv.Type().NumOut() > 2 || fv.Type().NumOut() == 2 && fv.Type().Out(1) != reflect.TypeFor[error]() {
+			return nil, &BuildError{Param: fmt.Sprintf("argument %d", arg), GoType: paramType, FuncType: funcType,
+				Reason: fmt.Errorf("prepare function must be a func(T) P or func(T) (P, error), but is %T", fn)}
+		}
+		if !fv.Type().Out(0).AssignableTo(paramType) {
+			return nil, &BuildError{Param: fmt.Sprintf("argument %d", arg), GoType: paramType, FuncType: funcType,
+				Reason: fmt.Errorf("prepare function returns %s, which cannot be passed as %s", fv.Type().Out(0), paramType)}
+		}
+		preparers[arg] = &preparer{fn: fv, inputType: fv.Type().In(0), hasError: fv.Type().NumOut() == 2}
+	}
+	return preparers, nil
+}
+
+// prepare converts an argument value to the input type of the prepare function and calls it.
+func (p *preparer) prepare(value driver.Value) (result driver.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in prepare function: %v", r)
+		}
+	}()
+	input, err := convert.FromDuckDB(value, p.inputType)
+	if err != nil {
+		return nil, err
+	}
+	out := p.fn.Call([]reflect.Value{input})
+	if p.hasError && !out[1].IsNil() {
+		return nil, out[1].Interface().(error)
+	}
+	return out[0].Interface(), nil
+}
+
+// prepareConstants prepares the constant arguments of a call being bound, which are not prepared again per row.
+func (asf *autoScalarFunc) prepareConstants(args []duckdb.ScalarUDFArg) (map[int]driver.Value, error) {
+	constants := make(map[int]driver.Value)
+	for arg, p := range asf.prepared {
+		if arg >= len(args) || !args[arg].Foldable || args[arg].Value == nil {
+			continue // Prepared for every row, or NULL
+		}
+		v, err := p.prepare(args[arg].Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: cannot prepare argument %d: %w", asf.describe(), arg, err)
+		}
+		constants[arg] = v
+	}
+	return constants, nil
+}
+
+// preparedArgs returns the arguments of a row with the prepared arguments replaced by the constants prepared
+// when binding the function, or prepared now. The input arguments are not modified.
+func (asf *autoScalarFunc) preparedArgs(constants map[int]driver.Value, inputArgs []driver.Value) ([]driver.Value, error) {
+	args := slices.Clone(inputArgs)
+	for arg, p := range asf.prepared {
+		if arg >= len(args) || args[arg] == nil {
+			continue
+		}
+		if v, ok := constants[arg]; ok {
+			args[arg] = v
+			continue
+		}
+		v, err := p.prepare(args[arg])
+		if err != nil {
+			return nil, asf.conversionError(arg, false, args[arg], asf.goArgTypes[arg], err)
+		}
+		args[arg] = v
+	}
+	return args, nil
+}
@@ -0,0 +1,114 @@
+package udf
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithPrepare(t *testing.T) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get DB connection: %v", err)
+	}
+	defer conn.Close()
+
+	var compiles atomic.Int64
+	compile := func(pattern string) (*regexp.Regexp, error) {
+		compiles.Add(1)
+		return regexp.Compile(pattern)
+	}
+	match := func(s string, re *regexp.Regexp) bool { return re.MatchString(s) }
+	if err := Register(conn, "go_regex_match", match, WithPrepare(1, compile)); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	// A constant pattern is compiled once for the query, not per row or chunk
+	result := querySingleValueOnConn(t, conn,
+		"SELECT count(*) FILTER (WHERE go_regex_match(i::VARCHAR, '^1.*0$')) FROM range(100000) t(i)")
+	if result != int64(1111) {
+		t.Errorf("count = %v, want 1111", result)
+	}
+	if n := compiles.Swap(0); n != 1 {
+		t.Errorf("pattern compiled %d times, want 1", n)
+	}
+
+	// An invalid constant fails the query when it is bound
+	_, err = conn.ExecContext(context.Background(), "SELECT go_regex_match('a', '(')")
+	if err == nil || !strings.Contains(err.Error(), "cannot prepare argument 1") ||
+		!strings.Contains(err.Error(), "missing closing )") {
+		t.Errorf("expected a bind error for the invalid pattern, got %v", err)
+	}
+	compiles.Store(0)
+
+	// Patterns read from a column are compiled per row, and NULL is not prepared
+	rows, err := conn.QueryContext(context.Background(),
+		"SELECT go_regex_match(s, p) FROM (VALUES ('abc', 'b'), ('abc', '^b'), ('abc', NULL)) t(s, p)")
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	var got []any
+	for rows.Next() {
+		var v any
+		if err := rows.Scan(&v); err != nil {
+			t.Fatalf("scan failed: %v", err)
+		}
+		got = append(got, v)
+	}
+	rows.Close()
+	want := []any{true, false, nil}
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("results = %v, want %v", got, want)
+		}
+	}
+	if n := compiles.Load(); n != 2 {
+		t.Errorf("pattern compiled %d times, want 2", n)
+	}
+
+	// An invalid pattern of a row fails the query with a conversion error
+	_, err = conn.ExecContext(context.Background(), "SELECT go_regex_match('a', p) FROM (VALUES ('(')) t(p)")
+	if err == nil || !strings.Contains(err.Error(), "missing closing )") {
+		t.Errorf("expected an error for the invalid pattern, got %v", err)
+	}
+}
+
+func TestWithPrepareBuildErrors(t *testing.T) {
+	match := func(s string, re *regexp.Regexp) bool { return re.MatchString(s) }
+	tests := []struct {
+		name string
+		fn   any
+		opt  func(*udfOption)
+	}{
+		{"out of range", match, WithPrepare(2, regexp.Compile)},
+		{"variadic", func(s ...string) bool { return true }, WithPrepare(0, strings.ToUpper)},
+		{"not a function", match, WithPrepare(1, "pattern")},
+		{"two parameters", match, WithPrepare(1, strings.Repeat)},
+		{"second result not an error", match, WithPrepare(1, func(string) (*regexp.Regexp, bool) { return nil, false })},
+		{"wrong result type", match, WithPrepare(1, strings.ToUpper)},
+		{"unsupported input", match, WithPrepare(1, func(chan int) *regexp.Regexp { return nil })},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := BuildScalarUDF(tt.fn, tt.opt)
+			var buildErr *BuildError
+			if !errors.As(err, &buildErr) {
+				t.Errorf("expected a BuildError, got %v", err)
+			}
+		})
+	}
+
+	// Without a prepare function, the parameter type must be supported
+	if _, err := BuildScalarUDF(match); err == nil {
+		t.Error("expected an error for the unsupported *regexp.Regexp parameter")
+	}
+}
@@ -1,12 +1,16 @@
 package udf
 
 import (
+	"context"
 	"database/sql/driver"
+	"errors"
 	"fmt"
 	"reflect"
-	"runtime"
+	"runtime/pprof"
+	"time"
 
 	"github.com/duckdb/duckdb-go/v2"
+	"github.com/ma6174/duckgo/internal/convert"
 )
 
 // autoScalarFunc is an internal struct that implements the duckdb.ScalarFunc interface.
@@ -20,8 +24,33 @@ type autoScalarFunc struct {
 	specialNullHandling  bool
 	volatile             bool
 
+	timeout        time.Duration // Maximum duration of a single call, 0 means no limit
+	maxResultBytes int           // Maximum size of a single result, 0 means no limit
+	limitPolicy    LimitPolicy   // What to do when a call exceeds a limit
+	name           string        // SQL name of the function for error messages, may be empty
+
 	isVariadic             bool            // Flag indicating if this is a variadic UDF
 	duckDBVariadicTypeInfo duckdb.TypeInfo // TypeInfo for the variadic part (based on element type)
+
+	generated GeneratedExecutor // Converts arguments and calls userFunc without reflection, nil for the reflective path
+
+	observers      []Observer // Notified of every row
+	observedName   string     // Name reported to observers and the profiler
+	profilerLabels bool       // Whether executions add the pprof label udf=<name> to the labels of the query
+
+	cache      Cache         // Results by arguments, nil if disabled
+	chunkDedup bool          // Whether to call the function once per distinct arguments of a chunk
+	slots      chan struct{} // Holds a value per running call if the concurrency is limited, nil otherwise
+
+	prepared map[int]*preparer // Prepare functions by argument index, nil if none
+	state    *stateSpec        // Creates the state passed as first parameter, nil if none
+
+	panicPolicy PanicPolicy // What to do when the user function panics
+	argRedactor ArgRedactor // Formats arguments in panic errors, nil for values and types
+	stackDepth  int         // Maximum frames in panic errors, 0 for the default, negative for none
+
+	defaults []driver.Value // Values of the trailing parameters with defaults, converted to their Go types
+	omitted  int            // Number of trailing arguments this overload takes from defaults
 }
 
 // Config method adjusted to set VariadicTypeInfo
@@ -38,17 +67,54 @@ func (asf *autoScalarFunc) Config() duckdb.ScalarFuncConfig {
 	return conf
 }
 
+// describe returns the UDF as it appears in error messages, e.g. "UDF (func type func(int) int)",
+// or "UDF double (func type func(int) int)" if the SQL name is known.
+func (asf *autoScalarFunc) describe() string {
+	if asf.name != "" {
+		return fmt.Sprintf("UDF %s (func type %s)", asf.name, asf.userFunc.Type().String())
+	}
+	return fmt.Sprintf("UDF (func type %s)", asf.userFunc.Type().String())
+}
+
+// conversionError describes the failed conversion of the input argument at argIndex to targetType.
+func (asf *autoScalarFunc) conversionError(argIndex int, variadic bool, value driver.Value, targetType reflect.Type, reason error) error {
+	var sourceType reflect.Type
+	if value != nil {
+		sourceType = reflect.TypeOf(value)
+	}
+	return &ConversionError{
+		FuncName:   asf.name,
+		FuncType:   asf.userFunc.Type(),
+		ArgIndex:   argIndex,
+		Variadic:   variadic,
+		SourceType: sourceType,
+		TargetType: targetType,
+		Reason:     reason,
+	}
+}
+
+// checkArgCount validates the number of arguments DuckDB passed for one row.
+func (asf *autoScalarFunc) checkArgCount(numInputArgs int) error {
+	numFormalGoParams := len(asf.goArgTypes)
+	if asf.isVariadic {
+		if numFixedGoParams := numFormalGoParams - 1; numInputArgs < numFixedGoParams {
+			return fmt.Errorf("UDF (variadic, func type %s) requires at least %d fixed parameters, but only %d were provided",
+				asf.userFunc.Type().String(), numFixedGoParams, numInputArgs)
+		}
+		return nil
+	}
+	if numFormalGoParams -= asf.omitted; numInputArgs != numFormalGoParams {
+		return fmt.Errorf("UDF (non-variadic, func type %s) requires %d parameters, but %d were provided",
+			asf.userFunc.Type().String(), numFormalGoParams, numInputArgs)
+	}
+	return nil
+}
+
 // Helper function to process variadic arguments
 func (asf *autoScalarFunc) processVariadicArgs(inputArgs []driver.Value, numFixedGoParams int) ([]reflect.Value, error) {
 	// Total number of formal parameters in the function signature
 	numFormalGoParams := len(asf.goArgTypes)
 
-	// Validate argument count
-	if len(inputArgs) < numFixedGoParams {
-		return nil, fmt.Errorf("UDF (variadic, func type %s) requires at least %d fixed parameters, but only %d were provided",
-			asf.userFunc.Type().String(), numFixedGoParams, len(inputArgs))
-	}
-
 	// Calculate the total number of arguments needed for reflect.Call()
 	numVariadicInputsProvided := len(inputArgs) - numFixedGoParams
 	numCallArgs := numFixedGoParams + numVariadicInputsProvided
@@ -58,10 +124,9 @@ func (asf *autoScalarFunc) processVariadicArgs(inputArgs []driver.Value, numFixe
 	for i := range numFixedGoParams {
 		goArgType := asf.goArgTypes[i] // Type of the i-th fixed Go parameter
 		duckDBVal := inputArgs[i]
-		convertedVal, conversionErr := convertToReflectValue(duckDBVal, goArgType)
+		convertedVal, conversionErr := convert.FromDuckDB(duckDBVal, goArgType)
 		if conversionErr != nil {
-			return nil, fmt.Errorf("error converting fixed parameter %d (Go type %s, func type %s): %w",
-				i, goArgType.String(), asf.userFunc.Type().String(), conversionErr)
+			return nil, asf.conversionError(i, false, duckDBVal, goArgType, conversionErr)
 		}
 		callArgs[i] = convertedVal
 	}
@@ -73,10 +138,9 @@ func (asf *autoScalarFunc) processVariadicArgs(inputArgs []driver.Value, numFixe
 
 	for i := range numVariadicInputsProvided {
 		duckDBVal := inputArgs[numFixedGoParams+i]
-		convertedVal, conversionErr := convertToReflectValue(duckDBVal, variadicGoElemType)
+		convertedVal, conversionErr := convert.FromDuckDB(duckDBVal, variadicGoElemType)
 		if conversionErr != nil {
-			return nil, fmt.Errorf("error converting variadic parameter %d (overall input arg %d, Go element type %s, func type %s): %w",
-				i, numFixedGoParams+i, variadicGoElemType.String(), asf.userFunc.Type().String(), conversionErr)
+			return nil, asf.conversionError(numFixedGoParams+i, true, duckDBVal, variadicGoElemType, conversionErr)
 		}
 		callArgs[numFixedGoParams+i] = convertedVal
 	}
@@ -88,21 +152,14 @@ func (asf *autoScalarFunc) processVariadicArgs(inputArgs []driver.Value, numFixe
 func (asf *autoScalarFunc) processNonVariadicArgs(inputArgs []driver.Value) ([]reflect.Value, error) {
 	numFormalGoParams := len(asf.goArgTypes)
 
-	// Validate argument count
-	if len(inputArgs) != numFormalGoParams {
-		return nil, fmt.Errorf("UDF (non-variadic, func type %s) requires %d parameters, but %d were provided",
-			asf.userFunc.Type().String(), numFormalGoParams, len(inputArgs))
-	}
-
 	// Convert all parameters
 	callArgs := make([]reflect.Value, numFormalGoParams)
 	for i := range numFormalGoParams {
 		goArgType := asf.goArgTypes[i]
 		duckDBVal := inputArgs[i]
-		convertedVal, conversionErr := convertToReflectValue(duckDBVal, goArgType)
+		convertedVal, conversionErr := convert.FromDuckDB(duckDBVal, goArgType)
 		if conversionErr != nil {
-			return nil, fmt.Errorf("error converting parameter %d (Go type %s, func type %s): %w",
-				i, goArgType.String(), asf.userFunc.Type().String(), conversionErr)
+			return nil, asf.conversionError(i, false, duckDBVal, goArgType, conversionErr)
 		}
 		callArgs[i] = convertedVal
 	}
@@ -110,59 +167,152 @@ func (asf *autoScalarFunc) processNonVariadicArgs(inputArgs []driver.Value) ([]r
 	return callArgs, nil
 }
 
-// Executor().RowExecutor method simplified to use the new helper functions
+// prepareCall converts the arguments of one row and returns a function calling the user function with them.
+// Arguments with a prepare function are replaced by their constants prepared at bind time, or prepared now.
+// With WithState, the call takes a state of the query q.
+func (asf *autoScalarFunc) prepareCall(q *queryState, inputArgs []driver.Value) (func() any, error) {
+	if err := asf.checkArgCount(len(inputArgs)); err != nil {
+		return nil, err
+	}
+	if asf.prepared != nil {
+		var err error
+		var constants map[int]driver.Value
+		if q != nil {
+			constants = q.constants
+		}
+		if inputArgs, err = asf.preparedArgs(constants, inputArgs); err != nil {
+			return nil, err
+		}
+	}
+	if asf.omitted > 0 {
+		inputArgs = asf.withDefaultArgs(inputArgs) // Defaults of prepared arguments are prepared when building
+	}
+
+	if asf.generated != nil {
+		call, err := asf.generated(inputArgs)
+		var convErr *ConversionError
+		if errors.As(err, &convErr) && convErr.FuncType == nil {
+			// Generated code does not know the registered function, see ConvertArg
+			convErr.FuncName = asf.name
+			convErr.FuncType = asf.userFunc.Type()
+		}
+		return call, err
+	}
+
+	var callArgs []reflect.Value
+	var err error
+	if asf.isVariadic {
+		// Last formal parameter is the variadic slice itself
+		callArgs, err = asf.processVariadicArgs(inputArgs, len(asf.goArgTypes)-1)
+	} else {
+		callArgs, err = asf.processNonVariadicArgs(inputArgs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if asf.state != nil {
+		return asf.withState(q, callArgs)
+	}
+	return func() any {
+		return asf.userFunc.Call(callArgs)[0].Interface()
+	}, nil
+}
+
+// Executor returns the functions executing the UDF. duckdb-go gets them for every chunk of rows.
 func (asf *autoScalarFunc) Executor() duckdb.ScalarFuncExecutor {
-	return duckdb.ScalarFuncExecutor{
-		RowExecutor: func(inputArgs []driver.Value) (result any, err error) {
-			defer func() {
-				if r := recover(); r != nil {
-					// Provide richer error context, including function type, parameter info, and stack trace
-					argValues := make([]string, len(inputArgs))
-					for i, arg := range inputArgs {
-						if arg == nil {
-							argValues[i] = "NULL"
-						} else {
-							argValues[i] = fmt.Sprintf("%v (type %T)", arg, arg)
-						}
-					}
-
-					// Get stack trace
-					buf := make([]byte, 4096)
-					n := runtime.Stack(buf, false)
-					stackTrace := string(buf[:n])
-
-					err = fmt.Errorf("panic in UDF (func type %s): %v\nParameters: %v\nStack trace:\n%s",
-						asf.userFunc.Type().String(), r, argValues, stackTrace)
+	rows := func(ctx context.Context, q *queryState) duckdb.RowExecutorFn {
+		var labels context.Context
+		if asf.profilerLabels {
+			labels = pprof.WithLabels(ctx, pprof.Labels("udf", asf.observedName))
+		}
+		execute := func(inputArgs []driver.Value) (any, error) {
+			return asf.executeRow(q, labels, inputArgs)
+		}
+		if asf.chunkDedup {
+			return dedupRows(execute)
+		}
+		return execute
+	}
+	if asf.prepared == nil && asf.state == nil && !asf.profilerLabels {
+		return duckdb.ScalarFuncExecutor{RowExecutor: rows(nil, nil)}
+	}
+	var execute duckdb.RowExecutorFn // Set by the first row, the rows of a chunk share the context of their bind
+	executor := duckdb.ScalarFuncExecutor{
+		RowContextExecutor: func(ctx context.Context, inputArgs []driver.Value) (any, error) {
+			if execute == nil {
+				if ctx == nil {
+					ctx = context.Background()
 				}
-			}()
-
-			// Total number of formal parameters in the function signature
-			numFormalGoParams := len(asf.goArgTypes)
-			numFixedGoParams := numFormalGoParams
-			if asf.isVariadic {
-				numFixedGoParams-- // Last formal parameter is the variadic slice itself
+				execute = rows(ctx, queryStateOf(ctx))
 			}
+			return execute(inputArgs)
+		},
+	}
+	if asf.prepared != nil || asf.state != nil {
+		executor.ScalarBinder = asf.bind
+	}
+	return executor
+}
 
-			// Process arguments and call the function
-			var callArgs []reflect.Value
-			var argsErr error
+// executeRow executes the UDF for the arguments of one row of a query with state q, nil if none.
+// The goroutine is tagged with the pprof labels of the context labels, if not nil.
+func (asf *autoScalarFunc) executeRow(q *queryState, labels context.Context, inputArgs []driver.Value) (result any, err error) {
+	if labels != nil {
+		defer setProfilerLabels(labels)()
+	}
+	var panicked bool
+	var panicValue any
+	if len(asf.observers) > 0 {
+		start := time.Now()
+		defer func() { asf.observe(start, err, panicked, panicValue) }()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			panicked, panicValue = true, r
+			result, err = asf.panicError(r, asf.stackTrace(), inputArgs)
+		}
+	}()
 
-			if asf.isVariadic {
-				callArgs, argsErr = asf.processVariadicArgs(inputArgs, numFixedGoParams)
-			} else {
-				callArgs, argsErr = asf.processNonVariadicArgs(inputArgs)
-			}
+	var key string
+	if asf.cache != nil {
+		key = cacheKey(inputArgs)
+		if cached, ok := asf.cache.Get(key); ok {
+			return cached, nil
+		}
+	}
 
-			if argsErr != nil {
-				return nil, argsErr
-			}
+	call, err := asf.prepareCall(q, inputArgs)
+	if err != nil {
+		return nil, err
+	}
+	if asf.slots != nil {
+		call = asf.limitConcurrency(call)
+	}
+
+	// Call the user function, on a separate goroutine if the call is time-limited
+	var userReturnVal any
+	if asf.timeout > 0 {
+		res, err := asf.callWithTimeout(call)
+		if err != nil {
+			return asf.limitExceeded(err)
+		}
+		if res.panicked {
+			panicked, panicValue = true, res.panicValue
+			return asf.panicError(res.panicValue, res.stackTrace, inputArgs)
+		}
+		userReturnVal = res.result
+	} else {
+		userReturnVal = call()
+	}
 
-			// Call the user function
-			results := asf.userFunc.Call(callArgs)
-			userReturnVal := results[0].Interface()
+	if err := asf.checkResultSize(userReturnVal); err != nil {
+		return asf.limitExceeded(err)
+	}
 
-			// Convert Go return value to DuckDB-compatible value
-			return convertGoToDuckDBValue(userReturnVal)
-		},
+	// Convert Go return value to DuckDB-compatible value
+	result, err = convert.ToDuckDB(userReturnVal)
+	if asf.cache != nil && err == nil {
+		asf.cache.Add(key, result)
 	}
+	return result, err
 }
@@ -0,0 +1,195 @@
+package udf
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+// WithState gives every call of the UDF a state of type S as its first parameter, which is not an SQL argument.
+// States are created with init for each query, and a state is used by a single call at a time, so states can hold
+// resources that are expensive or not thread-safe, such as tokenizers, scratch buffers or file handles:
+//
+//	count := func(tok *Tokenizer, text string) int { return len(tok.Split(text)) }
+//	err := udf.Register(conn, "token_count", count, udf.WithState(NewTokenizer, (*Tokenizer).Close))
+//	// SELECT token_count(body) FROM documents
+//
+// States are pooled per query rather than owned by DuckDB threads, which UDFs cannot identify: a call takes
+// an unused state of its query, created if there is none, and returns it to the query when it returns. A query
+// thus creates at most as many states as DuckDB threads call the UDF at the same time, and consecutive calls on
+// a thread may get different states. An error of init fails the query.
+//
+// teardown, if not nil, is called for each state of a query once the context passed to QueryContext or ExecContext
+// is done. DuckDB does not tell UDFs that a query ended, but runs the whole query before QueryContext returns, so
+// cancelling its context once the rows are read tears the states down:
+//
+//	ctx, cancel := context.WithCancel(ctx)
+//	defer cancel()
+//	rows, err := conn.QueryContext(ctx, "SELECT token_count(body) FROM documents")
+//
+// Prepared statements keep their states until the context passed to PrepareContext is done.
+//
+// teardown is only guaranteed for queries run with a context that is eventually done. With a context that is
+// never done, such as context.Background() or that of db.Query, the states are only torn down if the garbage
+// collector finds the query released by DuckDB, which may be late or never. Use a cancellable context for
+// states that must be released. UDFs with a state cannot be built with BuildGeneratedScalarUDF.
+func WithState[S any](init func() (S, error), teardown func(S)) func(*udfOption) {
+	return func(o *udfOption) {
+		o.state = &stateSpec{
+			stateType: reflect.TypeFor[S](),
+			init: func() (reflect.Value, error) {
+				s, err := init()
+				return reflect.ValueOf(&s).Elem(), err
+			},
+		}
+		if teardown != nil {
+			o.state.teardown = func(v reflect.Value) {
+				s, _ := v.Interface().(S) // Nil interfaces are not S
+				teardown(s)
+			}
+		}
+	}
+}
+
+// stateSpec creates and tears down the states of a UDF.
+type stateSpec struct {
+	stateType reflect.Type
+	init      func() (reflect.Value, error)
+	teardown  func(reflect.Value) // nil if none
+}
+
+// checkStateParam checks that the first parameter of funcType takes the state.
+func (spec *stateSpec) checkStateParam(funcType reflect.Type) error {
+	if funcType.NumIn() == 0 || funcType.IsVariadic() && funcType.NumIn() == 1 {
+		return &BuildError{Param: "state", GoType: spec.stateType, FuncType: funcType,
+			Reason: errors.New("function must take the state as its first parameter")}
+	}
+	if !spec.stateType.AssignableTo(funcType.In(0)) {
+		return &BuildError{Param: "state", GoType: spec.stateType, FuncType: funcType,
+			Reason: fmt.Errorf("state cannot be passed as the first parameter of type %s", funcType.In(0))}
+	}
+	return nil
+}
+
+// statePool holds the states of a query that are not used by a call.
+type statePool struct {
+	spec      *stateSpec
+	mu        sync.Mutex
+	free      []reflect.Value
+	closed    bool        // Whether the query is done, states are then torn down when they are put back
+	stopAfter func() bool // Unregisters the close at the end of the query context
+}
+
+// get returns an unused state, created if there is none.
+func (p *statePool) get() (reflect.Value, error) {
+	p.mu.Lock()
+	if n := len(p.free); n > 0 {
+		s := p.free[n-1]
+		p.free = p.free[:n-1]
+		p.mu.Unlock()
+		return s, nil
+	}
+	p.mu.Unlock()
+	return p.spec.init()
+}
+
+// put makes a state returned by get available to other calls.
+func (p *statePool) put(s reflect.Value) {
+	p.mu.Lock()
+	if !p.closed {
+		p.free = append(p.free, s)
+		p.mu.Unlock()
+		return
+	}
+	p.mu.Unlock()
+	p.spec.tearDown(s)
+}
+
+// close tears down the unused states, and the states still used once they are put back.
+func (p *statePool) close() {
+	p.mu.Lock()
+	free, stopAfter := p.free, p.stopAfter
+	p.free, p.closed, p.stopAfter = nil, true, nil
+	p.mu.Unlock()
+	if stopAfter != nil {
+		stopAfter()
+	}
+	for _, s := range free {
+		p.spec.tearDown(s)
+	}
+}
+
+// tearDown calls the teardown function for a state, if any. Panics are dropped, as there is no query to fail.
+func (spec *stateSpec) tearDown(s reflect.Value) {
+	if spec.teardown == nil {
+		return
+	}
+	defer func() { _ = recover() }()
+	spec.teardown(s)
+}
+
+// queryState is what a query binding the UDF passes to the execution of its rows.
+type queryState struct {
+	constants map[int]driver.Value // Arguments prepared at bind time, by argument index
+	states    *statePool           // nil without WithState
+}
+
+// queryStateKey is the key of the *queryState in the bind context.
+type queryStateKey struct{}
+
+// bind is the ScalarBinder of UDFs with prepared arguments or a state. It prepares the constant arguments
+// and returns the state of the query in the context passed to its rows.
+func (asf *autoScalarFunc) bind(ctx context.Context, args []duckdb.ScalarUDFArg) (context.Context, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := &queryState{}
+	if asf.prepared != nil {
+		constants, err := asf.prepareConstants(args)
+		if err != nil {
+			return nil, err
+		}
+		q.constants = constants
+	}
+	if asf.state != nil {
+		// ctx is the context of the query, or of the prepared statement. Without one that is ever done,
+		// the states are only torn down if the garbage collector finds that DuckDB dropped q.
+		p := &statePool{spec: asf.state}
+		p.mu.Lock()
+		p.stopAfter = context.AfterFunc(ctx, p.close)
+		p.mu.Unlock()
+		runtime.AddCleanup(q, func(p *statePool) { p.close() }, p)
+		q.states = p
+	}
+	return context.WithValue(ctx, queryStateKey{}, q), nil
+}
+
+// queryStateOf returns the state of the query set by bind, or nil.
+func queryStateOf(ctx context.Context) *queryState {
+	if ctx == nil {
+		return nil
+	}
+	q, _ := ctx.Value(queryStateKey{}).(*queryState)
+	return q
+}
+
+// withState wraps a call of the user function taking args to pass it a state of the query.
+func (asf *autoScalarFunc) withState(q *queryState, args []reflect.Value) (func() any, error) {
+	if q == nil || q.states == nil {
+		return nil, fmt.Errorf("%s: no state for the query", asf.describe())
+	}
+	s, err := q.states.get()
+	if err != nil {
+		return nil, fmt.Errorf("%s: cannot create state: %w", asf.describe(), err)
+	}
+	return func() any {
+		defer q.states.put(s)
+		return asf.userFunc.Call(append([]reflect.Value{s}, args...))[0].Interface()
+	}, nil
+}
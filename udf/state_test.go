@@ -0,0 +1,173 @@
+package udf
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// scratch is a state that must not be used by concurrent calls.
+type scratch struct {
+	inUse    atomic.Bool
+	tornDown atomic.Bool
+	buf      []byte
+}
+
+func TestWithState(t *testing.T) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get DB connection: %v", err)
+	}
+	defer conn.Close()
+
+	var created, shared, usedTornDown atomic.Int64
+	newScratch := func() (*scratch, error) {
+		created.Add(1)
+		return &scratch{}, nil
+	}
+	tornDown := make(chan *scratch, 1024)
+	release := func(s *scratch) {
+		s.tornDown.Store(true)
+		tornDown <- s
+	}
+	upper := func(s *scratch, text string) string {
+		if !s.inUse.CompareAndSwap(false, true) {
+			shared.Add(1)
+		}
+		if s.tornDown.Load() {
+			usedTornDown.Add(1)
+		}
+		defer s.inUse.Store(false)
+		s.buf = append(s.buf[:0], text...)
+		return strings.ToUpper(string(s.buf))
+	}
+	if err := Register(conn, "scratch_upper", upper, WithState(newScratch, release)); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	var threads int64
+	if err := conn.QueryRowContext(context.Background(), "SELECT current_setting('threads')").Scan(&threads); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var result int64
+	if err := conn.QueryRowContext(ctx,
+		"SELECT count(*) FILTER (WHERE scratch_upper('x' || i::VARCHAR) = 'X' || i::VARCHAR) FROM range(200000) t(i)").
+		Scan(&result); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if result != 200000 {
+		t.Errorf("count = %v, want 200000", result)
+	}
+	if n := shared.Load(); n != 0 {
+		t.Errorf("states used by %d concurrent calls", n)
+	}
+	if n := created.Load(); n < 1 || n > threads {
+		t.Errorf("%d states created, want between 1 and %d", n, threads)
+	}
+
+	// The states are torn down once the context of the query is done
+	cancel()
+	for i := range created.Load() {
+		select {
+		case <-tornDown:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d of %d states torn down", i, created.Load())
+		}
+	}
+
+	// Each query has its own states. A query with a context that is never done still gets fresh states,
+	// which are not torn down while it runs
+	before := created.Load()
+	querySingleValueOnConn(t, conn, "SELECT scratch_upper('a')")
+	if created.Load() != before+1 {
+		t.Errorf("%d states created for a new query, want 1", created.Load()-before)
+	}
+	before = created.Load()
+	if err := conn.QueryRowContext(context.Background(),
+		"SELECT count(*) FILTER (WHERE scratch_upper('x' || i::VARCHAR) = 'X' || i::VARCHAR) FROM range(200000) t(i)").
+		Scan(&result); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if result != 200000 {
+		t.Errorf("count = %v, want 200000", result)
+	}
+	if n := created.Load() - before; n < 1 || n > threads {
+		t.Errorf("%d states created, want between 1 and %d", n, threads)
+	}
+	if n := usedTornDown.Load(); n != 0 {
+		t.Errorf("states used by %d calls after their teardown", n)
+	}
+	if n := len(tornDown); int64(n) > created.Load()-before {
+		t.Errorf("%d states torn down, more than the %d created", n, created.Load()-before)
+	}
+}
+
+func TestWithStateInitError(t *testing.T) {
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("Failed to open DuckDB: %v", err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get DB connection: %v", err)
+	}
+	defer conn.Close()
+
+	open := func() (*scratch, error) { return nil, errors.New("no more handles") }
+	length := func(s *scratch, text string) int { return len(text) }
+	if err := Register(conn, "scratch_len", length, WithState(open, nil)); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	_, err = conn.ExecContext(context.Background(), "SELECT scratch_len('abc')")
+	if err == nil || !strings.Contains(err.Error(), "cannot create state: no more handles") {
+		t.Errorf("expected the init error, got %v", err)
+	}
+}
+
+func TestWithStateBuildErrors(t *testing.T) {
+	newScratch := func() (*scratch, error) { return &scratch{}, nil }
+	tests := []struct {
+		name string
+		fn   any
+	}{
+		{"no parameters", func() int { return 0 }},
+		{"variadic only", func(s ...*scratch) int { return 0 }},
+		{"wrong type", func(s string) int { return 0 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := BuildScalarUDF(tt.fn, WithState(newScratch, nil))
+			var buildErr *BuildError
+			if !errors.As(err, &buildErr) || buildErr.Param != "state" {
+				t.Errorf("expected a BuildError for the state, got %v", err)
+			}
+		})
+	}
+
+	// The state parameter is not an SQL argument
+	sf, err := BuildScalarUDF(func(s *scratch, a string, b ...int) string { return a }, WithState(newScratch, nil))
+	if err != nil {
+		t.Fatalf("BuildScalarUDF failed: %v", err)
+	}
+	if config := sf.Config(); len(config.InputTypeInfos) != 1 || config.VariadicTypeInfo == nil {
+		t.Errorf("unexpected SQL signature %+v", config)
+	}
+
+	exec := func(args []driver.Value) (func() any, error) { return nil, nil }
+	if _, err := BuildGeneratedScalarUDF(func(s *scratch) int { return 0 }, exec, WithState(newScratch, nil)); err == nil {
+		t.Error("expected an error for a generated UDF with a state")
+	}
+}
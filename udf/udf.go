@@ -72,8 +72,72 @@
 //	getTime := func() time.Time { return time.Now() }
 //	udfImpl, _ := udf.BuildScalarUDF(getTime, udf.WithVolatile(true))
 //
+// 4. Execution limits (fail the row, or return NULL, when a call runs too long or returns too much data):
+//
+//	udfImpl, _ := udf.BuildScalarUDF(slowLookup,
+//		udf.WithTimeout(time.Second),
+//		udf.WithMaxResultBytes(1<<20),
+//		udf.WithLimitPolicy(udf.LimitReturnNull),
+//	)
+//
+// 5. Result caching (reuse the results of expensive non-volatile functions for repeated arguments):
+//
+//	cache := udf.LRU(10000) // or udf.LRUWithTTL(10000, time.Hour)
+//	udfImpl, _ := udf.BuildScalarUDF(parseUserAgent, udf.WithCache(cache))
+//	// cache.Stats() reports hits, misses and evictions
+//
+// 6. Concurrency limits (DuckDB calls UDFs from several worker threads at once):
+//
+//	udfImpl, _ := udf.BuildScalarUDF(notThreadSafe, udf.WithSerialized())
+//	udfImpl, _ := udf.BuildScalarUDF(callsRateLimitedAPI, udf.WithMaxConcurrency(4))
+//
+// 7. Default values (trailing arguments SQL callers may omit, registered as overloads):
+//
+//	// SELECT format_money(12.5), format_money(12.5, 'EUR'), format_money(12.5, 'JPY', 0)
+//	err := udf.Register(conn, "format_money", formatMoney, udf.WithDefaults("USD", 2))
+//
+// 8. Chunk deduplication (one call per distinct argument list within each chunk of rows, e.g. constant arguments):
+//
+//	udfImpl, _ := udf.BuildScalarUDF(parseUserAgent, udf.WithChunkDedup(true))
+//
+// 9. Prepared arguments (constant arguments turned into Go values once per query, e.g. compiled patterns):
+//
+//	match := func(s string, re *regexp.Regexp) bool { return re.MatchString(s) }
+//	udfImpl, _ := udf.BuildScalarUDF(match, udf.WithPrepare(1, regexp.Compile))
+//
+// 10. Per-query state (a pool of resources per query, passed as first parameter and torn down with the query context):
+//
+//	count := func(tok *Tokenizer, text string) int { return len(tok.Split(text)) }
+//	udfImpl, _ := udf.BuildScalarUDF(count, udf.WithState(NewTokenizer, (*Tokenizer).Close))
+//
 // # Error Handling
 //
-// Panics during UDF execution are caught and converted to SQL errors with detailed context information.
-// Errors during UDF building and registration also return detailed error messages.
+// Panics during UDF execution are caught and converted to SQL errors with detailed context information:
+// the panic value and the arguments, both shown only by type with WithArgRedactor(TypesOnly), and up to
+// WithStackDepth stack frames.
+// WithPanicPolicy can return NULL for the row instead, or let the panic terminate the process.
+// BuildScalarUDF returns a *BuildError naming the unsupported parameter, and arguments that cannot be
+// converted to their Go type produce a *ConversionError; both can be inspected with errors.As.
+// Pass WithName with the SQL name of the function to have it included in runtime error messages:
+//
+//	udfImpl, _ := udf.BuildScalarUDF(myAdd, udf.WithName("my_add"))
+//	duckdb.RegisterScalarUDF(conn, "my_add", udfImpl)
+//
+// # Monitoring
+//
+// WithObserver reports every row a UDF processes, with its duration and outcome, to an Observer.
+// The built-in Collector keeps counters and latency histograms per function, which RegisterStatsFunction
+// exposes to SQL as duckgo_udf_stats(). WithProfilerLabels tags CPU profiles with the function name,
+// next to the labels of the query context:
+//
+//	stats := udf.NewCollector()
+//	udfImpl, _ := udf.BuildScalarUDF(myAdd, udf.WithName("my_add"), udf.WithObserver(stats))
+//	udf.RegisterStatsFunction(conn, stats)
+//	// SELECT name, calls, errors, panics, p99_ms FROM duckgo_udf_stats()
+//
+// # Generated Wrappers
+//
+// The "duckgo gen" command generates code that converts arguments and calls compiled functions without
+// reflection, through BuildGeneratedScalarUDF and ConvertArg. The generated UDFs behave like those built
+// by BuildScalarUDF.
 package udf
@@ -0,0 +1,152 @@
+package udftest
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+)
+
+// Equal reports whether a Go value and a value returned by the driver represent the same SQL value.
+// Both are normalized as described in the package documentation before comparing; NaN equals NaN.
+func Equal(want, got any) bool {
+	return equalNormalized(normalize(want), normalize(got))
+}
+
+// normalize converts v to a canonical form of the SQL value it represents: integers to int64,
+// or uint64 if they do not fit, floats to float64, times to UTC with microsecond precision,
+// lists to []any, STRUCT values and Go structs to map[string]any, and MAP values and Go maps to map[any]any.
+func normalize(v any) any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return v.UTC().Truncate(time.Microsecond)
+	case *big.Int: // HUGEINT
+		if v.IsInt64() {
+			return v.Int64()
+		}
+		return v.String()
+	case duckdb.OrderedMap:
+		m := make(map[any]any, v.Len())
+		values := v.Values()
+		for i, k := range v.Keys() {
+			m[mapKey(k)] = normalize(values[i])
+		}
+		return m
+	case map[string]any: // STRUCT
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[k] = normalize(e)
+		}
+		return m
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := rv.Uint()
+		if u > math.MaxInt64 {
+			return u
+		}
+		return int64(u)
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 { // BLOB
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return b
+		}
+		l := make([]any, rv.Len())
+		for i := range l {
+			l[i] = normalize(rv.Index(i).Interface())
+		}
+		return l
+	case reflect.Map:
+		m := make(map[any]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[mapKey(iter.Key().Interface())] = normalize(iter.Value().Interface())
+		}
+		return m
+	case reflect.Struct:
+		m := make(map[string]any)
+		for i := 0; i < rv.NumField(); i++ {
+			if field := rv.Type().Field(i); field.IsExported() {
+				m[field.Name] = normalize(rv.Field(i).Interface())
+			}
+		}
+		return m
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return normalize(rv.Elem().Interface())
+	default:
+		return v
+	}
+}
+
+// mapKey normalizes a MAP key. Keys that cannot be Go map keys once normalized, such as BLOB or LIST keys,
+// are represented by their formatted value.
+func mapKey(k any) any {
+	nk := normalize(k)
+	if nk != nil && !reflect.TypeOf(nk).Comparable() {
+		return fmt.Sprintf("%v", nk)
+	}
+	return nk
+}
+
+// equalNormalized compares two normalized values.
+func equalNormalized(a, b any) bool {
+	switch a := a.(type) {
+	case float64:
+		b, ok := b.(float64)
+		return ok && (a == b || math.IsNaN(a) && math.IsNaN(b))
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equalNormalized(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			if w, ok := b[k]; !ok || !equalNormalized(v, w) {
+				return false
+			}
+		}
+		return true
+	case map[any]any:
+		b, ok := b.(map[any]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			if w, ok := b[k]; !ok || !equalNormalized(v, w) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
@@ -0,0 +1,91 @@
+package udftest
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("udftest.update", false, "rewrite udftest golden files with the current results")
+
+// Golden evaluates each expression and compares the results with the golden file at path,
+// conventionally in the testdata directory of the package. Each line of the file holds an expression,
+// the Go type of its value and the value, NULL, or the first line of the error it failed with:
+//
+//	my_add(1, 2) => int32 3
+//	my_add(NULL, 2) => NULL
+//	my_add('x', 2) => ERROR Conversion Error: Could not convert string 'x' to INT32
+//
+// Run the tests with -udftest.update to write the file from the current results,
+// and review the diff before committing it.
+func (h *Harness) Golden(t testing.TB, path string, exprs ...string) {
+	t.Helper()
+	var b strings.Builder
+	for _, expr := range exprs {
+		v, err := h.Eval(expr)
+		fmt.Fprintf(&b, "%s => %s\n", oneLine(expr), formatResult(v, err))
+	}
+	got := b.String()
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("udftest: writing golden file: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("udftest: writing golden file: %v", err)
+		}
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("udftest: golden file %s does not exist, run the test with -udftest.update to create it", path)
+	} else if err != nil {
+		t.Fatalf("udftest: reading golden file: %v", err)
+	}
+	want := string(data)
+	if got == want {
+		return
+	}
+	gotLines, wantLines := strings.Split(got, "\n"), strings.Split(want, "\n")
+	for i := 0; i < max(len(gotLines), len(wantLines)); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			t.Errorf("%s:%d:\n got: %s\nwant: %s", path, i+1, g, w)
+		}
+	}
+}
+
+// formatResult formats the outcome of evaluating an expression for a golden file.
+func formatResult(v any, err error) string {
+	switch {
+	case err != nil:
+		msg, _, _ := strings.Cut(err.Error(), "\n") // Stack traces differ between machines
+		return "ERROR " + msg
+	case v == nil:
+		return "NULL"
+	}
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("%T %q", v, v)
+	case []byte:
+		return fmt.Sprintf("%T %q", v, v)
+	default:
+		return fmt.Sprintf("%T %s", v, strings.ReplaceAll(fmt.Sprintf("%v", v), "\n", `\n`))
+	}
+}
+
+// oneLine joins the lines of s with spaces.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
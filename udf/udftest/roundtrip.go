@@ -0,0 +1,269 @@
+package udftest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/duckdb/duckdb-go/v2"
+	"github.com/ma6174/duckgo/internal/convert"
+)
+
+// RoundTripConfig configures Harness.RoundTrip. The zero value runs 100 iterations with generated arguments.
+type RoundTripConfig struct {
+	// Iterations is the number of argument lists to check, 100 if zero.
+	Iterations int
+	// Seed of the random generator. Runs with the same seed check the same arguments.
+	Seed int64
+	// Generate returns the arguments of one call, which must be assignable to the parameters of the function.
+	// By default, arguments are generated from the parameter types: numbers, strings, []byte, booleans
+	// and times, and maps, structs and pointers of them.
+	Generate func(r *rand.Rand) []any
+	// NoOverflow keeps generated integers small, or within half the range of their SQL type, so that sums
+	// of two arguments fit; set it for functions doing arithmetic. By default, integers span the range of
+	// their Go type.
+	NoOverflow bool
+}
+
+// RoundTrip calls the function of the harness with generated arguments, once directly and once through SQL
+// with the arguments bound as query parameters, and reports every call whose results differ.
+// It finds differences in how values of the parameter and result types survive the conversion to and
+// from DuckDB, such as integers out of the range of the SQL type, e.g. Go int values beyond INTEGER,
+// or times losing precision. A call panicking in Go must fail in SQL. Variadic functions are called with
+// up to three variadic arguments.
+func (h *Harness) RoundTrip(t testing.TB, cfg RoundTripConfig) {
+	t.Helper()
+	if cfg.Iterations == 0 {
+		cfg.Iterations = 100
+	}
+	fn := reflect.ValueOf(h.fn)
+	ft := fn.Type()
+	r := rand.New(rand.NewSource(cfg.Seed))
+	generate := cfg.Generate
+	if generate == nil {
+		generate = func(r *rand.Rand) []any { return generateArgs(r, ft, cfg.NoOverflow) }
+	}
+
+	for range cfg.Iterations {
+		args := generate(r)
+		placeholders := make([]string, len(args))
+		for i := range args {
+			placeholders[i] = "?::" + sqlType(paramType(ft, i))
+		}
+		expr := fmt.Sprintf("%s(%s)", h.name, strings.Join(placeholders, ", "))
+		bound := make([]any, len(args))
+		for i, arg := range args {
+			bound[i] = bindValue(reflect.ValueOf(arg))
+		}
+		got, sqlErr := h.Eval(expr, bound...)
+		want, panicValue, panicked := call(fn, args)
+
+		switch {
+		case panicked && sqlErr == nil:
+			t.Errorf("%s%v panicked with %v, but returned %#v through SQL", h.name, args, panicValue, got)
+		case panicked:
+			// Failed both ways
+		case sqlErr != nil:
+			t.Errorf("%s%v = %#v, but failed through SQL: %v", h.name, args, want, sqlErr)
+		case !Equal(want, got):
+			t.Errorf("%s%v = %#v, but %#v (%T) through SQL", h.name, args, want, got, got)
+		}
+	}
+}
+
+// call calls fn with args, recovering a panic.
+func call(fn reflect.Value, args []any) (result, panicValue any, panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicValue, panicked = r, true
+		}
+	}()
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		pt := paramType(fn.Type(), i)
+		if arg == nil {
+			in[i] = reflect.Zero(pt)
+		} else {
+			in[i] = reflect.ValueOf(arg).Convert(pt)
+		}
+	}
+	return fn.Call(in)[0].Interface(), nil, false
+}
+
+// bindValue converts an argument to a value the driver can bind: structs to map[string]any, maps to duckdb.Map,
+// and unsigned integers above math.MaxInt64, which database/sql rejects, to their decimal form cast by the
+// placeholder.
+func bindValue(v reflect.Value) any {
+	if v.Type() == reflect.TypeOf(time.Time{}) {
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		return bindValue(v.Elem())
+	case reflect.Struct:
+		m := make(map[string]any)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				m[v.Type().Field(i).Name] = bindValue(v.Field(i))
+			}
+		}
+		return m
+	case reflect.Map:
+		m := make(duckdb.Map, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[bindValue(iter.Key())] = bindValue(iter.Value())
+		}
+		return m
+	case reflect.Uint, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return strconv.FormatUint(v.Uint(), 10)
+		}
+		return v.Interface()
+	default:
+		return v.Interface()
+	}
+}
+
+// sqlType returns the SQL type the udf package maps a Go parameter type to.
+// Placeholders are cast to it, because the driver binds all integers as BIGINT.
+func sqlType(t reflect.Type) string {
+	name, err := convert.SQLType(t)
+	if err != nil {
+		return t.String() // Unsupported types fail to register before reaching SQL
+	}
+	return name
+}
+
+// paramType returns the type of the i-th argument of a call of a function of type ft.
+func paramType(ft reflect.Type, i int) reflect.Type {
+	if ft.IsVariadic() && i >= ft.NumIn()-1 {
+		return ft.In(ft.NumIn() - 1).Elem()
+	}
+	return ft.In(i)
+}
+
+// generateArgs generates the arguments of one call of a function of type ft, with integers within half the
+// range of their SQL type if noOverflow is set.
+func generateArgs(r *rand.Rand, ft reflect.Type, noOverflow bool) []any {
+	n := ft.NumIn()
+	if ft.IsVariadic() {
+		n += r.Intn(4) - 1
+	}
+	args := make([]any, n)
+	for i := range args {
+		args[i] = generate(r, paramType(ft, i), noOverflow).Interface()
+	}
+	return args
+}
+
+// generate returns a random value of type t, as described at generateArgs.
+func generate(r *rand.Rand, t reflect.Type, noOverflow bool) reflect.Value {
+	v := reflect.New(t).Elem()
+	if t == reflect.TypeOf(time.Time{}) {
+		// Microseconds between 1900 and 2100, the precision of TIMESTAMP
+		const lo, hi = -2208988800 * 1e6, 4102444800 * 1e6
+		v.Set(reflect.ValueOf(time.UnixMicro(lo + r.Int63n(hi-lo)).UTC()))
+		return v
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		bits := t.Bits()
+		if !noOverflow {
+			v.SetInt(generateFullInt(r, bits))
+			break
+		}
+		if t.Kind() == reflect.Int {
+			bits = 32 // INTEGER
+		}
+		v.SetInt(generateInt(r, bits))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		bits := t.Bits()
+		if !noOverflow {
+			v.SetUint(generateUint(r, bits))
+			break
+		}
+		if t.Kind() == reflect.Uint {
+			bits = 32 // UINTEGER
+		}
+		n := generateInt(r, bits)
+		if n < 0 {
+			n = -n
+		}
+		v.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		if r.Intn(2) == 0 {
+			v.SetFloat(float64(r.Intn(201) - 100))
+		} else {
+			v.SetFloat(r.NormFloat64() * 1e6)
+		}
+	case reflect.String:
+		v.SetString(generateString(r))
+	case reflect.Bool:
+		v.SetBool(r.Intn(2) == 0)
+	case reflect.Slice:
+		n := r.Intn(4)
+		v.Set(reflect.MakeSlice(t, n, n))
+		for i := range n {
+			v.Index(i).Set(generate(r, t.Elem(), noOverflow))
+		}
+	case reflect.Map:
+		v.Set(reflect.MakeMap(t))
+		for range r.Intn(4) {
+			v.SetMapIndex(generate(r, t.Key(), noOverflow), generate(r, t.Elem(), noOverflow))
+		}
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				v.Field(i).Set(generate(r, t.Field(i).Type, noOverflow))
+			}
+		}
+	case reflect.Pointer:
+		v.Set(reflect.New(t.Elem()))
+		v.Elem().Set(generate(r, t.Elem(), noOverflow))
+	}
+	return v
+}
+
+// generateInt returns a small integer, or one within half the range of a signed integer of the given size.
+func generateInt(r *rand.Rand, bits int) int64 {
+	if r.Intn(2) == 0 {
+		return int64(r.Intn(201) - 100)
+	}
+	limit := int64(math.MaxInt64 >> (65 - bits))
+	return r.Int63n(2*limit+1) - limit
+}
+
+// generateFullInt returns a small integer, or one anywhere in the range of a signed integer of the given size.
+func generateFullInt(r *rand.Rand, bits int) int64 {
+	if r.Intn(2) == 0 {
+		return int64(r.Intn(201) - 100)
+	}
+	return int64(r.Uint64()) >> (64 - bits)
+}
+
+// generateUint returns a small integer, or one anywhere in the range of an unsigned integer of the given size.
+func generateUint(r *rand.Rand, bits int) uint64 {
+	if r.Intn(2) == 0 {
+		return uint64(r.Intn(101))
+	}
+	return r.Uint64() >> (64 - bits)
+}
+
+// stringRunes are the characters generated strings are made of, including multi-byte ones.
+var stringRunes = []rune("abcXYZ019 _-'\"%\\\t\néü中文😀")
+
+// generateString returns a string of up to 16 characters.
+func generateString(r *rand.Rand) string {
+	rs := make([]rune, r.Intn(17))
+	for i := range rs {
+		rs[i] = stringRunes[r.Intn(len(stringRunes))]
+	}
+	return string(rs)
+}
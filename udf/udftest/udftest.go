@@ -0,0 +1,161 @@
+// Package udftest provides a test harness for DuckDB UDFs built with the udf package.
+//
+// A Harness opens an in-memory DuckDB database, registers a Go function as a UDF and evaluates SQL
+// expressions against it, so tests can be written as tables of SQL expressions and expected results:
+//
+//	func TestAdd(t *testing.T) {
+//		h := udftest.New(t, "my_add", func(a, b int) int { return a + b })
+//		h.Run(t, []udftest.Case{
+//			{SQL: "my_add(1, 2)", Want: 3},
+//			{SQL: "my_add(NULL, 2)", WantNull: true},
+//			{SQL: "my_add('x', 2)", WantErr: "Could not convert"},
+//		})
+//	}
+//
+// Golden compares the results of a list of expressions with a file in testdata, and RoundTrip checks
+// on generated inputs that calling the function through SQL gives the same result as calling it directly.
+//
+// Results are compared after normalizing both sides to the values DuckDB returns, so Want can use plain
+// Go values: every integer type compares equal to the same number of any other integer type, structs
+// compare equal to the STRUCT values DuckDB returns for them, Go maps to MAP values, and time.Time
+// values are compared in UTC with the microsecond precision of TIMESTAMP.
+// Floats are compared exactly, so write a float32 value as Want for FLOAT results.
+package udftest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ma6174/duckgo/udf"
+)
+
+// Harness is an in-memory DuckDB database with a UDF registered, closed when the test finishes.
+type Harness struct {
+	// DB is the database of the harness.
+	DB *sql.DB
+	// Conn is the connection all expressions are evaluated on. Use it to create tables or register
+	// further functions needed by the test cases.
+	Conn *sql.Conn
+
+	name string
+	fn   any
+}
+
+// New opens an in-memory DuckDB database and registers fn as a UDF called name with udf.Register,
+// passing opts. The test fails
+// immediately if the database cannot be opened or fn cannot be registered.
+func New(t testing.TB, name string, fn any, opts ...udf.Option) *Harness {
+	t.Helper()
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("udftest: opening DuckDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("udftest: getting DB connection: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	h := &Harness{DB: db, Conn: conn, name: name, fn: fn}
+	h.Register(t, name, fn, opts...)
+	return h
+}
+
+// Register registers another function on the harness, e.g. a helper used in the test cases
+// of the function under test. It fails the test immediately if fn cannot be registered.
+func (h *Harness) Register(t testing.TB, name string, fn any, opts ...udf.Option) {
+	t.Helper()
+	if err := udf.Register(h.Conn, name, fn, opts...); err != nil {
+		t.Fatalf("udftest: registering %s: %v", name, err)
+	}
+}
+
+// Eval evaluates a SQL expression, such as "my_add(1, 2)", and returns its value as returned by the driver,
+// nil for SQL NULL. args are bound to the placeholders of the expression. Expr may also be a complete
+// query returning a single value, e.g. to read arguments from a table, which keeps DuckDB from
+// evaluating constant arguments at planning time.
+func (h *Harness) Eval(expr string, args ...any) (any, error) {
+	query := expr
+	if !isQuery(expr) {
+		query = "SELECT " + expr
+	}
+	var v any
+	if err := h.Conn.QueryRowContext(context.Background(), query, args...).Scan(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// isQuery reports whether s is a complete query rather than an expression.
+func isQuery(s string) bool {
+	word, _, _ := strings.Cut(strings.TrimSpace(s), " ")
+	switch strings.ToUpper(word) {
+	case "SELECT", "WITH", "FROM", "VALUES":
+		return true
+	default:
+		return false
+	}
+}
+
+// Case is a test case evaluated by Run. It expects SQL NULL if WantNull is set or Want is nil,
+// and an error if WantErr is set.
+type Case struct {
+	// Name of the subtest, the SQL expression if empty.
+	Name string
+	// SQL is the expression or query to evaluate, see Harness.Eval.
+	SQL string
+	// Args are bound to the placeholders of SQL. The driver binds all integers as BIGINT,
+	// so cast placeholders of other integer parameters, e.g. "my_add(?::INTEGER, ?::INTEGER)".
+	Args []any
+	// Want is the expected value.
+	Want any
+	// WantNull expects SQL NULL.
+	WantNull bool
+	// WantErr expects an error containing this text.
+	WantErr string
+}
+
+// Run evaluates every case in a subtest and reports results that differ from the expectation.
+func (h *Harness) Run(t *testing.T, cases []Case) {
+	t.Helper()
+	for _, c := range cases {
+		name := c.Name
+		if name == "" {
+			name = c.SQL
+		}
+		t.Run(name, func(t *testing.T) {
+			t.Helper()
+			got, err := h.Eval(c.SQL, c.Args...)
+			if msg := c.check(got, err); msg != "" {
+				t.Fatal(msg)
+			}
+		})
+	}
+}
+
+// check returns a description of how the result of the case differs from the expectation,
+// or an empty string if it matches.
+func (c Case) check(got any, err error) string {
+	switch {
+	case c.WantErr != "":
+		if err == nil {
+			return fmt.Sprintf("%s = %#v, want error containing %q", c.SQL, got, c.WantErr)
+		}
+		if !strings.Contains(err.Error(), c.WantErr) {
+			return fmt.Sprintf("%s failed with %q, want error containing %q", c.SQL, err, c.WantErr)
+		}
+	case err != nil:
+		return fmt.Sprintf("%s failed: %v", c.SQL, err)
+	case c.WantNull || c.Want == nil:
+		if got != nil {
+			return fmt.Sprintf("%s = %#v, want NULL", c.SQL, got)
+		}
+	case !Equal(c.Want, got):
+		return fmt.Sprintf("%s = %#v (%T), want %#v (%T)", c.SQL, got, got, c.Want, c.Want)
+	}
+	return ""
+}
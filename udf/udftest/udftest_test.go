@@ -0,0 +1,208 @@
+package udftest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ma6174/duckgo/udf"
+)
+
+type point struct {
+	X, Y int32
+	Tag  string
+}
+
+// recorder is a testing.TB recording reported errors instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRun(t *testing.T) {
+	h := New(t, "my_add", func(a, b int) int { return a + b })
+	h.Register(t, "mkpoint", func(x, y int32, tag string) point { return point{x, y, tag} })
+	h.Register(t, "counts", func(s string) map[string]int64 {
+		m := make(map[string]int64)
+		for _, r := range s {
+			m[string(r)]++
+		}
+		return m
+	})
+	h.Register(t, "half", func(f float32) float32 { return f / 2 })
+	h.Register(t, "day", func(d int32) time.Time { return time.Date(2024, 1, int(d), 0, 0, 0, 0, time.UTC) })
+
+	h.Run(t, []Case{
+		{SQL: "my_add(1, 2)", Want: 3},
+		{SQL: "my_add(1, 2)", Want: int64(3)},
+		{SQL: "my_add(?::INTEGER, ?::INTEGER)", Args: []any{40, 2}, Want: uint8(42)},
+		{SQL: "my_add(NULL, 2)", WantNull: true},
+		{SQL: "my_add(1, 2) IS NULL", Want: false},
+		{SQL: "my_add('x', 2)", WantErr: "Could not convert string 'x' to INT32"},
+		{Name: "from table", SQL: "SELECT my_add(a, b) FROM (VALUES (5, 6)) t(a, b)", Want: 11},
+		{SQL: "mkpoint(1, 2, 'p')", Want: point{1, 2, "p"}},
+		{SQL: "mkpoint(1, 2, 'p')", Want: map[string]any{"X": 1, "Y": 2, "Tag": "p"}},
+		{SQL: "counts('abca')", Want: map[string]int{"a": 2, "b": 1, "c": 1}},
+		{SQL: "half(1.5)", Want: float32(0.75)},
+		{SQL: "day(2)", Want: time.Date(2024, 1, 2, 8, 0, 0, 0, time.FixedZone("UTC+8", 8*3600))},
+	})
+}
+
+func TestRunFailures(t *testing.T) {
+	h := New(t, "my_add", func(a, b int) int { return a + b })
+	cases := []Case{
+		{SQL: "my_add(1, 2)", Want: 4},
+		{SQL: "my_add(1, 2)", WantNull: true},
+		{SQL: "my_add(1, 2)", WantErr: "boom"},
+		{SQL: "my_add('x', 2)", Want: 3},
+		{SQL: "my_add('x', 2)", WantErr: "boom"},
+	}
+	for _, c := range cases {
+		got, err := h.Eval(c.SQL)
+		if msg := c.check(got, err); msg == "" {
+			t.Errorf("case %+v unexpectedly passes", c)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		want, got any
+		equal     bool
+	}{
+		{3, int32(3), true},
+		{uint64(1 << 63), uint64(1 << 63), true},
+		{3, uint32(3), true},
+		{3, float64(3), false},
+		{float32(0.1), float32(0.1), true},
+		{0.1, float32(0.1), false},
+		{[]int{1, 2}, []any{int32(1), int32(2)}, true},
+		{[]int{1, 2}, []any{int32(2), int32(1)}, false},
+		{[]byte("a"), []byte("a"), true},
+		{&point{1, 2, "p"}, map[string]any{"X": int32(1), "Y": int32(2), "Tag": "p"}, true},
+		{point{1, 2, "p"}, map[string]any{"X": int32(1), "Y": int32(2)}, false},
+		{nil, nil, true},
+		{(*point)(nil), nil, true},
+		{"", nil, false},
+	}
+	for _, tt := range tests {
+		if got := Equal(tt.want, tt.got); got != tt.equal {
+			t.Errorf("Equal(%#v, %#v) = %v, want %v", tt.want, tt.got, got, tt.equal)
+		}
+	}
+}
+
+func TestGolden(t *testing.T) {
+	h := New(t, "greet", func(name string) string {
+		if name == "" {
+			panic("empty name")
+		}
+		return "hello " + name
+	})
+	h.Golden(t, "testdata/greet.golden",
+		"greet('world')",
+		"greet(NULL)",
+		"SELECT greet(s) FROM (VALUES ('')) t(s)",
+		"length(greet('a\nb'))",
+	)
+	if *update {
+		return
+	}
+
+	r := &recorder{TB: t}
+	h.Golden(r, "testdata/greet.golden", "greet('world')", "greet('you')")
+	if len(r.errors) != 3 {
+		t.Fatalf("expected differences in 3 lines, got %q", r.errors)
+	}
+	if !strings.Contains(r.errors[0], `got: greet('you') => string "hello you"`) {
+		t.Errorf("unexpected difference reported: %q", r.errors[1])
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   any
+	}{
+		{"my_add", func(a, b int) int { return a + b }},
+		{"wide", func(a int64, b uint64, c uint32) int64 { return a/2 + int64(b%2) + int64(c%2) }},
+		{"sizes", func(a int8, b int16, c int64, d uint8, e uint16, f uint64) int64 {
+			return int64(a) + int64(b) + c%1000 + int64(d) + int64(e) + int64(f%1000)
+		}},
+		{"concat", func(sep string, parts ...string) string { return strings.Join(parts, sep) }},
+		{"scale", func(f float32, g float64) float64 { return float64(f) * g }},
+		{"blob", func(b []byte, ok bool) []byte {
+			if ok {
+				return append(b, 0)
+			}
+			return b
+		}},
+		{"later", func(ts time.Time, h int32) time.Time { return ts.Add(time.Duration(h%1000) * time.Hour) }},
+		{"div", func(a, b int32) int32 { return a / (b % 3) }},
+		{"norm", func(p point) float64 { return math.Hypot(float64(p.X), float64(p.Y)) }},
+		{"total", func(m map[string]int32) int64 {
+			var sum int64
+			for _, v := range m {
+				sum += int64(v)
+			}
+			return sum
+		}},
+		{"deref", func(p *point) string { return p.Tag }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			New(t, tt.name, tt.fn).RoundTrip(t, RoundTripConfig{Iterations: 50, NoOverflow: tt.name == "my_add"}) // Sums ints
+		})
+	}
+}
+
+func TestRoundTripFindsDifferences(t *testing.T) {
+	h := New(t, "grow", func(a int) int { return a * 1000 })
+	r := &recorder{TB: t}
+	h.RoundTrip(r, RoundTripConfig{
+		Generate:   func(r *rand.Rand) []any { return []any{1 << 25} },
+		Iterations: 1,
+	})
+	if len(r.errors) != 1 {
+		t.Fatalf("expected a difference for results out of the range of INTEGER, got %q", r.errors)
+	}
+
+	// Go int is INTEGER in SQL, which generated values overflow unless NoOverflow is set
+	h = New(t, "same", func(a int) int { return a })
+	r = &recorder{TB: t}
+	h.RoundTrip(r, RoundTripConfig{Iterations: 20})
+	if len(r.errors) == 0 {
+		t.Fatal("expected differences for ints out of the range of INTEGER")
+	}
+	r = &recorder{TB: t}
+	h.RoundTrip(r, RoundTripConfig{Iterations: 20, NoOverflow: true})
+	if len(r.errors) != 0 {
+		t.Fatalf("expected no differences with NoOverflow, got %q", r.errors)
+	}
+
+	h = New(t, "nanos", func(ts time.Time) time.Time { return ts.Add(time.Nanosecond) })
+	r = &recorder{TB: t}
+	h.RoundTrip(r, RoundTripConfig{Iterations: 10})
+	if len(r.errors) != 0 {
+		t.Fatalf("nanoseconds are not stored by TIMESTAMP and must be ignored, got %q", r.errors)
+	}
+
+	h = New(t, "parity", func(a int32) bool {
+		if a%2 == 0 {
+			panic("even")
+		}
+		return true
+	}, udf.WithPanicPolicy(udf.PanicReturnNull))
+	r = &recorder{TB: t}
+	h.RoundTrip(r, RoundTripConfig{Iterations: 10})
+	if len(r.errors) == 0 {
+		t.Fatal("expected differences for panics turned into NULL")
+	}
+}